@@ -0,0 +1,237 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+const (
+	defaultBatchConcurrency = 4
+)
+
+// ChunkError is the error returned by a single request sent by TrackAll or ImportAll
+type ChunkError struct {
+	// Offset is the index in the original slice of the first event of the chunk
+	Offset int
+	// Size is the number of events in the chunk
+	Size int
+	Err  error
+}
+
+func (e ChunkError) Error() string {
+	return fmt.Sprintf("events [%d:%d] failed: %s", e.Offset, e.Offset+e.Size, e.Err)
+}
+
+func (e ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// BatchError is returned when one or more chunks sent by TrackAll or ImportAll failed
+type BatchError struct {
+	// Chunks are sorted by offset
+	Chunks []ChunkError
+}
+
+func (e BatchError) Error() string {
+	return fmt.Sprintf("%d chunks failed, first error: %s", len(e.Chunks), e.Chunks[0].Error())
+}
+
+// Unwrap returns the first chunk error
+func (e BatchError) Unwrap() error {
+	return e.Chunks[0]
+}
+
+type ImportAllResult struct {
+	NumRecordsImported int
+	// FailedImportRecords has the index of the event in the slice passed to ImportAll
+	FailedImportRecords []ImportFailedRecords
+}
+
+type eventChunk struct {
+	start int
+	end   int
+}
+
+// splitChunks splits total items into chunks of at most size
+func splitChunks(total, size int) []eventChunk {
+	chunks := make([]eventChunk, 0, (total+size-1)/size)
+	for start := 0; start < total; start += size {
+		end := start + size
+		if end > total {
+			end = total
+		}
+		chunks = append(chunks, eventChunk{start: start, end: end})
+	}
+	return chunks
+}
+
+// sendChunks calls send for every chunk with at most concurrency calls in flight
+func sendChunks(ctx context.Context, chunks []eventChunk, concurrency int, send func(ctx context.Context, chunk eventChunk) error) []ChunkError {
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	var (
+		wg          sync.WaitGroup
+		mu          sync.Mutex
+		chunkErrors []ChunkError
+		semaphore   = make(chan struct{}, concurrency)
+	)
+
+	for _, c := range chunks {
+		c := c
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+
+			if err := send(ctx, c); err != nil {
+				mu.Lock()
+				chunkErrors = append(chunkErrors, ChunkError{
+					Offset: c.start,
+					Size:   c.end - c.start,
+					Err:    err,
+				})
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	sort.Slice(chunkErrors, func(i, j int) bool {
+		return chunkErrors[i].Offset < chunkErrors[j].Offset
+	})
+	return chunkErrors
+}
+
+// TrackAll calls Track with chunks of at most MaxTrackEvents
+// Returns a BatchError if any of the chunks failed
+func (m *ApiClient) TrackAll(ctx context.Context, events []*Event) error {
+	chunkErrors := sendChunks(ctx, splitChunks(len(events), MaxTrackEvents), defaultBatchConcurrency, func(ctx context.Context, c eventChunk) error {
+		return m.Track(ctx, events[c.start:c.end])
+	})
+	if len(chunkErrors) > 0 {
+		return BatchError{Chunks: chunkErrors}
+	}
+	return nil
+}
+
+// splitChunksBySize splits the events into chunks of at most maxEvents events and maxBytes of uncompressed json
+// The api limits the uncompressed size of the request, so the compression is not taken into account
+// An event bigger than maxBytes is sent on its own
+func splitChunksBySize(events []*Event, maxEvents, maxBytes int) ([]eventChunk, error) {
+	var chunks []eventChunk
+	start, size := 0, 0
+	for i, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return nil, fmt.Errorf("failed to estimate event size: %w", err)
+		}
+		// json array brackets and the separating comma
+		eventSize := len(data) + 1
+		if i > start && (i-start >= maxEvents || size+eventSize+1 > maxBytes) {
+			chunks = append(chunks, eventChunk{start: start, end: i})
+			start, size = i, 0
+		}
+		size += eventSize
+	}
+	if start < len(events) {
+		chunks = append(chunks, eventChunk{start: start, end: len(events)})
+	}
+	return chunks, nil
+}
+
+// ImportAll calls Import with chunks of at most MaxImportEvents and options.MaxBatchBytes,
+// sending up to options.Concurrency requests at a time
+// Chunks rejected as too large by the api are split in half and sent again
+// Validation failures are mapped back to the index of the event in events
+// Returns a BatchError if any of the chunks failed
+func (a *ApiClient) ImportAll(ctx context.Context, events []*Event, options ImportOptions) (*ImportAllResult, error) {
+	maxBytes := options.MaxBatchBytes
+	if maxBytes <= 0 {
+		maxBytes = MaxImportBytes
+	}
+
+	// interceptors run once so the bisected chunks are not intercepted again
+	intercepted, indexes := a.intercept(ctx, events)
+	// the chunks are measured as they are sent, with the insert ids and the redaction applied
+	if err := a.addInsertIDs(intercepted); err != nil {
+		return nil, err
+	}
+	chunks, err := splitChunksBySize(a.redactEvents(intercepted), MaxImportEvents, maxBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &importAllResult{}
+	chunkErrors := sendChunks(ctx, chunks, options.Concurrency, func(ctx context.Context, c eventChunk) error {
+		return a.importBisect(ctx, intercepted[c.start:c.end], c.start, indexes, jsonPayload, options, result)
+	})
+	for i := range chunkErrors {
+		chunkErrors[i].Offset = originalIndex(indexes, chunkErrors[i].Offset)
+	}
+
+	sort.Slice(result.FailedImportRecords, func(i, j int) bool {
+		return result.FailedImportRecords[i].Index < result.FailedImportRecords[j].Index
+	})
+
+	if len(chunkErrors) > 0 {
+		return &result.ImportAllResult, BatchError{Chunks: chunkErrors}
+	}
+	return &result.ImportAllResult, nil
+}
+
+// importAllResult collects the results of the chunks sent concurrently
+type importAllResult struct {
+	ImportAllResult
+	mu sync.Mutex
+}
+
+// importBisect imports the events and splits them in half when the api responds the request is too large
+// offset is the index of events[0] in the intercepted events and indexes maps them to the slice passed to ImportAll
+func (a *ApiClient) importBisect(ctx context.Context, events []*Event, offset int, indexes []int, payload requestPostPayloadType, options ImportOptions, result *importAllResult) error {
+	success, err := a.importIntercepted(ctx, events, payload, options)
+	if err == nil {
+		result.mu.Lock()
+		result.NumRecordsImported += success.NumRecordsImported
+		result.mu.Unlock()
+		return nil
+	}
+
+	var genericError ImportGenericError
+	if errors.As(err, &genericError) && genericError.Code == http.StatusRequestEntityTooLarge && len(events) > 1 {
+		mid := len(events) / 2
+		leftErr := a.importBisect(ctx, events[:mid], offset, indexes, payload, options, result)
+		rightErr := a.importBisect(ctx, events[mid:], offset+mid, indexes, payload, options, result)
+		if leftErr != nil {
+			return leftErr
+		}
+		return rightErr
+	}
+
+	var validationError ImportFailedValidationError
+	if !errors.As(err, &validationError) {
+		return err
+	}
+
+	failedRecords := make([]ImportFailedRecords, len(validationError.FailedImportRecords))
+	for i, record := range validationError.FailedImportRecords {
+		record.Index = originalIndex(indexes, offset+record.Index)
+		failedRecords[i] = record
+	}
+	validationError.FailedImportRecords = failedRecords
+
+	result.mu.Lock()
+	result.NumRecordsImported += validationError.NumRecordsImported
+	result.FailedImportRecords = append(result.FailedImportRecords, failedRecords...)
+	result.mu.Unlock()
+	return validationError
+}
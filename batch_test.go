@@ -0,0 +1,277 @@
+package mixpanel
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeImportRequest decodes the events sent to the import endpoint
+func decodeImportRequest(t *testing.T, req *http.Request) []*Event {
+	var reader io.Reader = req.Body
+	if req.Header.Get("content-encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(req.Body)
+		require.NoError(t, err)
+		reader = gzipReader
+	}
+
+	var events []*Event
+	require.NoError(t, json.NewDecoder(reader).Decode(&events))
+	return events
+}
+
+func makeEvents(mp *ApiClient, count int) []*Event {
+	events := make([]*Event, count)
+	for i := range events {
+		events[i] = mp.NewEvent(fmt.Sprintf("event_%d", i), EmptyDistinctID, map[string]any{})
+	}
+	return events
+}
+
+func TestSplitChunks(t *testing.T) {
+	require.Empty(t, splitChunks(0, 10))
+	require.Equal(t, []eventChunk{{0, 10}}, splitChunks(10, 10))
+	require.Equal(t, []eventChunk{{0, 10}, {10, 20}, {20, 25}}, splitChunks(25, 10))
+}
+
+func TestSplitChunksBySize(t *testing.T) {
+	mp := NewApiClient("token")
+	events := makeEvents(mp, 10)
+
+	data, err := json.Marshal(events[:3])
+	require.NoError(t, err)
+
+	chunks, err := splitChunksBySize(events, MaxImportEvents, len(data))
+	require.NoError(t, err)
+	require.Equal(t, []eventChunk{{0, 3}, {3, 6}, {6, 9}, {9, 10}}, chunks)
+
+	chunks, err = splitChunksBySize(events, 4, MaxImportBytes)
+	require.NoError(t, err)
+	require.Equal(t, []eventChunk{{0, 4}, {4, 8}, {8, 10}}, chunks)
+
+	// an event larger than the limit is sent on its own
+	chunks, err = splitChunksBySize(events[:2], MaxImportEvents, 1)
+	require.NoError(t, err)
+	require.Equal(t, []eventChunk{{0, 1}, {1, 2}}, chunks)
+}
+
+func TestTrackAll(t *testing.T) {
+	t.Run("splits events into chunks", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var tracked int64
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			var r []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&r))
+			require.LessOrEqual(t, len(r), MaxTrackEvents)
+			atomic.AddInt64(&tracked, int64(len(r)))
+
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+
+		require.NoError(t, mp.TrackAll(ctx, makeEvents(mp, MaxTrackEvents*2+1)))
+		require.Equal(t, 3, httpmock.GetTotalCallCount())
+		require.Equal(t, int64(MaxTrackEvents*2+1), tracked)
+	})
+}
+
+func TestImportAll(t *testing.T) {
+	setupImportAllEndpoint := func(t *testing.T, client *ApiClient, responder func(events []*Event) (int, string)) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, importURL), func(req *http.Request) (*http.Response, error) {
+			status, body := responder(decodeImportRequest(t, req))
+			return httpmock.NewStringResponse(status, body), nil
+		})
+	}
+
+	t.Run("imports all the chunks", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		setupImportAllEndpoint(t, mp, func(events []*Event) (int, string) {
+			require.LessOrEqual(t, len(events), MaxImportEvents)
+			return http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(events))
+		})
+
+		result, err := mp.ImportAll(ctx, makeEvents(mp, MaxImportEvents*2+10), ImportOptionsRecommend)
+		require.NoError(t, err)
+		require.Equal(t, MaxImportEvents*2+10, result.NumRecordsImported)
+		require.Empty(t, result.FailedImportRecords)
+		require.Equal(t, 3, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("failed records are mapped to the original index", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		setupImportAllEndpoint(t, mp, func(events []*Event) (int, string) {
+			if events[0].Name != fmt.Sprintf("event_%d", MaxImportEvents) {
+				return http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(events))
+			}
+			return http.StatusBadRequest, fmt.Sprintf(`{
+				"code": 400,
+				"status": "Bad Request",
+				"num_records_imported": %d,
+				"error": "some data points in the request failed validation",
+				"failed_records": [{"index": 5, "field": "properties.time", "insert_id": "", "message": "'properties.time' is invalid"}]
+			}`, len(events)-1)
+		})
+
+		result, err := mp.ImportAll(ctx, makeEvents(mp, MaxImportEvents+100), ImportOptionsRecommend)
+		batchError := BatchError{}
+		require.ErrorAs(t, err, &batchError)
+		require.Len(t, batchError.Chunks, 1)
+		require.Equal(t, MaxImportEvents, batchError.Chunks[0].Offset)
+
+		validationError := ImportFailedValidationError{}
+		require.ErrorAs(t, err, &validationError)
+		require.Equal(t, MaxImportEvents+5, validationError.FailedImportRecords[0].Index)
+
+		require.Equal(t, MaxImportEvents+99, result.NumRecordsImported)
+		require.Len(t, result.FailedImportRecords, 1)
+		require.Equal(t, MaxImportEvents+5, result.FailedImportRecords[0].Index)
+	})
+
+	t.Run("splits batches by size", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		events := makeEvents(mp, 10)
+		setupImportAllEndpoint(t, mp, func(events []*Event) (int, string) {
+			return http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(events))
+		})
+
+		data, err := json.Marshal(events[:5])
+		require.NoError(t, err)
+		options := ImportOptionsRecommend
+		options.MaxBatchBytes = len(data)
+		result, err := mp.ImportAll(ctx, events, options)
+		require.NoError(t, err)
+		require.Equal(t, 10, result.NumRecordsImported)
+		require.Equal(t, 2, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("measures the events with their insert ids", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithInsertIDs(InsertIDRandom))
+		events := makeEvents(mp, 10)
+		data, err := json.Marshal(events[:5])
+		require.NoError(t, err)
+		maxBytes := len(data)
+
+		setupImportAllEndpoint(t, mp, func(events []*Event) (int, string) {
+			data, err := json.Marshal(events)
+			require.NoError(t, err)
+			require.LessOrEqual(t, len(data), maxBytes)
+			return http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(events))
+		})
+
+		options := ImportOptionsRecommend
+		options.MaxBatchBytes = maxBytes
+		result, err := mp.ImportAll(ctx, events, options)
+		require.NoError(t, err)
+		require.Equal(t, 10, result.NumRecordsImported)
+		require.Greater(t, httpmock.GetTotalCallCount(), 2)
+	})
+
+	t.Run("bisects batches that are too large", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		var sizes []int
+		setupImportAllEndpoint(t, mp, func(events []*Event) (int, string) {
+			sizes = append(sizes, len(events))
+			if len(events) > 2 {
+				return http.StatusRequestEntityTooLarge, `{"code": 413, "error": "request too large", "status": 0}`
+			}
+			return http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(events))
+		})
+
+		options := ImportOptionsRecommend
+		options.Concurrency = 1
+		result, err := mp.ImportAll(ctx, makeEvents(mp, 8), options)
+		require.NoError(t, err)
+		require.Equal(t, 8, result.NumRecordsImported)
+		require.Equal(t, []int{8, 4, 2, 2, 4, 2, 2}, sizes)
+	})
+
+	t.Run("bisects on a 413 that is not json", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		setupImportAllEndpoint(t, mp, func(events []*Event) (int, string) {
+			if len(events) > 2 {
+				return http.StatusRequestEntityTooLarge, "<html><body><h1>413 Request Entity Too Large</h1></body></html>"
+			}
+			return http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(events))
+		})
+
+		result, err := mp.ImportAll(ctx, makeEvents(mp, 4), ImportOptionsRecommend)
+		require.NoError(t, err)
+		require.Equal(t, 4, result.NumRecordsImported)
+		require.Equal(t, 3, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("maps failed records of bisected batches", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		setupImportAllEndpoint(t, mp, func(events []*Event) (int, string) {
+			if len(events) > 2 {
+				return http.StatusRequestEntityTooLarge, `{"code": 413, "error": "request too large", "status": 0}`
+			}
+			if events[0].Name == "event_2" {
+				return http.StatusBadRequest, `{"code": 400, "error": "invalid", "num_records_imported": 1, "failed_records": [{"index": 1, "field": "event", "message": "invalid"}]}`
+			}
+			return http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(events))
+		})
+
+		result, err := mp.ImportAll(ctx, makeEvents(mp, 4), ImportOptionsRecommend)
+		require.ErrorAs(t, err, &BatchError{})
+		require.Equal(t, 3, result.NumRecordsImported)
+		require.Len(t, result.FailedImportRecords, 1)
+		require.Equal(t, 3, result.FailedImportRecords[0].Index)
+	})
+
+	t.Run("a single event that is too large fails", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		setupImportAllEndpoint(t, mp, func(events []*Event) (int, string) {
+			return http.StatusRequestEntityTooLarge, `{"code": 413, "error": "request too large", "status": 0}`
+		})
+
+		_, err := mp.ImportAll(ctx, makeEvents(mp, 2), ImportOptionsRecommend)
+		batchError := BatchError{}
+		require.ErrorAs(t, err, &batchError)
+		require.Equal(t, 3, httpmock.GetTotalCallCount())
+		require.ErrorAs(t, err, &ImportGenericError{})
+	})
+
+	t.Run("reports chunks that could not be imported", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		setupImportAllEndpoint(t, mp, func(events []*Event) (int, string) {
+			return http.StatusTooManyRequests, `{"code": 429, "error": "rate limit exceeded", "status": 0}`
+		})
+
+		result, err := mp.ImportAll(ctx, makeEvents(mp, MaxImportEvents+1), ImportOptionsRecommend)
+		batchError := BatchError{}
+		require.ErrorAs(t, err, &batchError)
+		require.Len(t, batchError.Chunks, 2)
+		require.Equal(t, 0, batchError.Chunks[0].Offset)
+		require.Equal(t, MaxImportEvents, batchError.Chunks[1].Offset)
+		require.Equal(t, 1, batchError.Chunks[1].Size)
+
+		rateLimitError := ImportRateLimitError{}
+		require.ErrorAs(t, err, &rateLimitError)
+		require.Equal(t, 0, result.NumRecordsImported)
+	})
+}
@@ -0,0 +1,380 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CSVColumnType is the type a csv column is converted to
+type CSVColumnType int
+
+const (
+	// CSVString sends the value as is
+	CSVString CSVColumnType = iota
+	CSVInt
+	CSVFloat
+	// CSVBool accepts the values of strconv.ParseBool
+	CSVBool
+	// CSVDate parses the value with the Format of the column and sends it as a mixpanel date
+	CSVDate
+	// CSVList splits the value on the ListSeparator of the mapping
+	CSVList
+	// CSVJSON decodes the value as json
+	CSVJSON
+)
+
+// CSVColumn configures a property column
+type CSVColumn struct {
+	// Property is the name of the property, defaults to the column name
+	Property string
+	Type     CSVColumnType
+	// Format is the time layout of a CSVDate column, defaults to time.RFC3339
+	Format string
+}
+
+// CSVMapping describes how the rows of a csv file with a header are converted to events
+// Every column that is not mapped to the event name, distinct_id, time or insert_id and is not ignored is sent as a property
+type CSVMapping struct {
+	// EventColumn is the column with the event name, EventName is used for every row when it is empty
+	EventColumn string
+	EventName   string
+
+	DistinctIDColumn string
+
+	// TimeColumn is the column with the event time
+	TimeColumn string
+	// TimeFormat is the time layout of TimeColumn, empty for unix timestamps in seconds or milliseconds
+	TimeFormat string
+	// TimeLocation is used for the layouts without a time zone, defaults to UTC
+	TimeLocation *time.Location
+
+	InsertIDColumn string
+
+	// Columns configures the property columns, the columns not listed are sent as strings
+	Columns map[string]CSVColumn
+	// IgnoreColumns are not sent
+	IgnoreColumns []string
+
+	// ListSeparator splits the values of CSVList columns, defaults to ","
+	ListSeparator string
+	// KeepEmpty sends empty values as empty strings instead of leaving the property out
+	KeepEmpty bool
+}
+
+// CSVRowError is returned for a row that could not be converted to an event
+type CSVRowError struct {
+	// Line is the line of the row in the file, the header is line 1
+	Line int
+	// Column is empty when the row could not be parsed
+	Column string
+	Err    error
+}
+
+func (e CSVRowError) Error() string {
+	if e.Column == "" {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+	}
+	return fmt.Sprintf("line %d, column %q: %s", e.Line, e.Column, e.Err)
+}
+
+func (e CSVRowError) Unwrap() error {
+	return e.Err
+}
+
+type csvPropertyColumn struct {
+	index    int
+	column   string
+	property string
+	config   CSVColumn
+}
+
+// CSVEventReader converts the rows of a csv file to events
+type CSVEventReader struct {
+	client  *ApiClient
+	reader  *csv.Reader
+	mapping CSVMapping
+
+	eventIndex      int
+	distinctIDIndex int
+	timeIndex       int
+	insertIDIndex   int
+	properties      []csvPropertyColumn
+
+	// headerSize is added to the size of every row to estimate the size of its json
+	headerSize int
+	offset     int64
+}
+
+// NewCSVEventReader reads the header of the csv and returns an error if a column of the mapping is missing
+func (m *ApiClient) NewCSVEventReader(r io.Reader, mapping CSVMapping) (*CSVEventReader, error) {
+	if mapping.EventColumn == "" && mapping.EventName == "" {
+		return nil, errors.New("csv mapping needs an EventColumn or an EventName")
+	}
+	if mapping.TimeLocation == nil {
+		mapping.TimeLocation = time.UTC
+	}
+	if mapping.ListSeparator == "" {
+		mapping.ListSeparator = ","
+	}
+
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read csv header: %w", err)
+	}
+
+	columns := make(map[string]int, len(header))
+	headerSize := 0
+	for i, name := range header {
+		if _, ok := columns[name]; ok {
+			return nil, fmt.Errorf("duplicate csv column %q", name)
+		}
+		columns[name] = i
+		headerSize += len(name)
+	}
+	indexOf := func(name string) (int, error) {
+		if name == "" {
+			return -1, nil
+		}
+		index, ok := columns[name]
+		if !ok {
+			return -1, fmt.Errorf("csv column %q is missing", name)
+		}
+		return index, nil
+	}
+
+	c := &CSVEventReader{
+		client:     m,
+		reader:     reader,
+		mapping:    mapping,
+		headerSize: headerSize,
+		offset:     reader.InputOffset(),
+	}
+	for _, role := range []struct {
+		column string
+		index  *int
+	}{
+		{mapping.EventColumn, &c.eventIndex},
+		{mapping.DistinctIDColumn, &c.distinctIDIndex},
+		{mapping.TimeColumn, &c.timeIndex},
+		{mapping.InsertIDColumn, &c.insertIDIndex},
+	} {
+		if *role.index, err = indexOf(role.column); err != nil {
+			return nil, err
+		}
+	}
+
+	skip := make(map[string]bool)
+	for _, name := range append([]string{mapping.EventColumn, mapping.DistinctIDColumn, mapping.TimeColumn, mapping.InsertIDColumn}, mapping.IgnoreColumns...) {
+		if name == "" {
+			continue
+		}
+		if _, err := indexOf(name); err != nil {
+			return nil, err
+		}
+		skip[name] = true
+	}
+	for name := range mapping.Columns {
+		if _, err := indexOf(name); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, name := range header {
+		if skip[name] {
+			continue
+		}
+		config := mapping.Columns[name]
+		property := config.Property
+		if property == "" {
+			property = name
+		}
+		c.properties = append(c.properties, csvPropertyColumn{
+			index:    i,
+			column:   name,
+			property: property,
+			config:   config,
+		})
+	}
+
+	return c, nil
+}
+
+// Read returns the event of the next row, a CSVRowError if the row could not be converted and io.EOF after the last row
+// The rows after a CSVRowError can still be read
+func (c *CSVEventReader) Read() (*Event, error) {
+	record, err := c.next()
+	if err != nil {
+		return nil, err
+	}
+	if record.err != nil {
+		return nil, record.err
+	}
+	return record.event, nil
+}
+
+// ReadAll returns the events of every row and the errors of the rows that could not be converted
+func (c *CSVEventReader) ReadAll() ([]*Event, []CSVRowError, error) {
+	var (
+		events    []*Event
+		rowErrors []CSVRowError
+	)
+	for {
+		record, err := c.next()
+		if errors.Is(err, io.EOF) {
+			return events, rowErrors, nil
+		}
+		if err != nil {
+			return events, rowErrors, err
+		}
+
+		rowError := CSVRowError{}
+		if errors.As(record.err, &rowError) {
+			rowErrors = append(rowErrors, rowError)
+			continue
+		}
+		events = append(events, record.event)
+	}
+}
+
+// next implements eventSource, the err of the record is always a CSVRowError
+func (c *CSVEventReader) next() (sourceRecord, error) {
+	row, err := c.reader.Read()
+	offset := c.reader.InputOffset()
+	size := int(offset-c.offset) + c.headerSize
+	c.offset = offset
+
+	parseError := &csv.ParseError{}
+	if errors.As(err, &parseError) {
+		return sourceRecord{
+			line: parseError.StartLine,
+			err:  CSVRowError{Line: parseError.StartLine, Err: parseError.Err},
+		}, nil
+	}
+	if err != nil {
+		return sourceRecord{}, err
+	}
+
+	line, _ := c.reader.FieldPos(0)
+	event, err := c.rowEvent(line, row)
+	return sourceRecord{
+		event: event,
+		line:  line,
+		size:  size,
+		err:   err,
+	}, nil
+}
+
+func (c *CSVEventReader) rowEvent(line int, row []string) (*Event, error) {
+	name := c.mapping.EventName
+	if c.eventIndex >= 0 {
+		name = row[c.eventIndex]
+		if name == "" {
+			return nil, CSVRowError{Line: line, Column: c.mapping.EventColumn, Err: errors.New("event name is empty")}
+		}
+	}
+
+	distinctID := EmptyDistinctID
+	if c.distinctIDIndex >= 0 {
+		distinctID = row[c.distinctIDIndex]
+	}
+
+	properties := make(map[string]any, len(c.properties))
+	for _, property := range c.properties {
+		value := row[property.index]
+		if value == "" {
+			if c.mapping.KeepEmpty {
+				properties[property.property] = value
+			}
+			continue
+		}
+
+		converted, err := c.convert(property.config, value)
+		if err != nil {
+			return nil, CSVRowError{Line: line, Column: property.column, Err: err}
+		}
+		properties[property.property] = converted
+	}
+
+	event := c.client.NewEvent(name, distinctID, properties)
+
+	if c.timeIndex >= 0 {
+		t, err := c.parseTime(row[c.timeIndex])
+		if err != nil {
+			return nil, CSVRowError{Line: line, Column: c.mapping.TimeColumn, Err: err}
+		}
+		event.AddTime(t)
+	}
+	if c.insertIDIndex >= 0 && row[c.insertIDIndex] != "" {
+		event.AddInsertID(row[c.insertIDIndex])
+	}
+
+	return event, nil
+}
+
+func (c *CSVEventReader) parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, errors.New("time is empty")
+	}
+	if c.mapping.TimeFormat != "" {
+		return time.ParseInLocation(c.mapping.TimeFormat, value, c.mapping.TimeLocation)
+	}
+
+	epoch, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected a unix timestamp: %w", err)
+	}
+	return timeFromEpoch(epoch), nil
+}
+
+func (c *CSVEventReader) convert(config CSVColumn, value string) (any, error) {
+	switch config.Type {
+	case CSVString:
+		return value, nil
+	case CSVInt:
+		return strconv.ParseInt(value, 10, 64)
+	case CSVFloat:
+		return strconv.ParseFloat(value, 64)
+	case CSVBool:
+		return strconv.ParseBool(value)
+	case CSVDate:
+		layout := config.Format
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		t, err := time.ParseInLocation(layout, value, c.mapping.TimeLocation)
+		if err != nil {
+			return nil, err
+		}
+		return t.UTC().Format(datePropertyLayout), nil
+	case CSVList:
+		return strings.Split(value, c.mapping.ListSeparator), nil
+	case CSVJSON:
+		var decoded any
+		if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+			return nil, err
+		}
+		return decoded, nil
+	default:
+		return nil, fmt.Errorf("unknown csv column type %d", config.Type)
+	}
+}
+
+// ImportCSV imports the rows of a csv file with a header in batches like ImportStream
+// The rows that could not be converted or failed validation are reported in FailedLines with the line of the row, the header is line 1,
+// and the column in the Field when the error is about a single column
+func (a *ApiClient) ImportCSV(ctx context.Context, r io.Reader, mapping CSVMapping, options ImportOptions) (*ImportStreamResult, error) {
+	reader, err := a.NewCSVEventReader(r, mapping)
+	if err != nil {
+		return nil, err
+	}
+	return a.importSource(ctx, reader, options)
+}
@@ -0,0 +1,185 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCSVEventReader(t *testing.T) {
+	mapping := CSVMapping{
+		EventColumn:      "action",
+		DistinctIDColumn: "user",
+		TimeColumn:       "at",
+		TimeFormat:       "2006-01-02 15:04:05",
+		InsertIDColumn:   "id",
+		Columns: map[string]CSVColumn{
+			"amount":  {Type: CSVFloat},
+			"items":   {Property: "item_count", Type: CSVInt},
+			"paid":    {Type: CSVBool},
+			"tags":    {Type: CSVList},
+			"meta":    {Type: CSVJSON},
+			"renewal": {Type: CSVDate, Format: "2006-01-02"},
+		},
+		IgnoreColumns: []string{"internal"},
+		ListSeparator: "|",
+	}
+
+	t.Run("converts rows to events", func(t *testing.T) {
+		mp := NewApiClient("token")
+		input := strings.Join([]string{
+			"action,user,at,id,amount,items,paid,tags,meta,renewal,internal,plan",
+			`purchase,user-1,2024-01-02 03:04:05,insert-1,9.99,3,true,a|b,"{""source"":""ads""}",2025-01-02,x,pro`,
+			"refund,user-2,2024-01-03 00:00:00,,,,,,,,,",
+		}, "\n")
+
+		reader, err := mp.NewCSVEventReader(strings.NewReader(input), mapping)
+		require.NoError(t, err)
+		events, rowErrors, err := reader.ReadAll()
+		require.NoError(t, err)
+		require.Empty(t, rowErrors)
+		require.Len(t, events, 2)
+
+		require.Equal(t, "purchase", events[0].Name)
+		require.Equal(t, map[string]any{
+			propertyToken:      "token",
+			propertyDistinctID: "user-1",
+			propertyMpLib:      goLib,
+			propertyLibVersion: version,
+			propertyTime:       time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).UnixMilli(),
+			propertyInsertID:   "insert-1",
+			"amount":           9.99,
+			"item_count":       int64(3),
+			"paid":             true,
+			"tags":             []string{"a", "b"},
+			"meta":             map[string]any{"source": "ads"},
+			"renewal":          "2025-01-02T00:00:00",
+			"plan":             "pro",
+		}, events[0].Properties)
+
+		require.Equal(t, "refund", events[1].Name)
+		require.NotContains(t, events[1].Properties, propertyInsertID)
+		require.NotContains(t, events[1].Properties, "amount")
+		require.NotContains(t, events[1].Properties, "internal")
+	})
+
+	t.Run("constant event name and unix time", func(t *testing.T) {
+		mp := NewApiClient("token")
+		input := "user,ts\nuser-1,1700000000\nuser-2,1700000000123\n"
+
+		reader, err := mp.NewCSVEventReader(strings.NewReader(input), CSVMapping{
+			EventName:        "signup",
+			DistinctIDColumn: "user",
+			TimeColumn:       "ts",
+		})
+		require.NoError(t, err)
+
+		event, err := reader.Read()
+		require.NoError(t, err)
+		require.Equal(t, "signup", event.Name)
+		require.Equal(t, int64(1700000000000), event.Properties[propertyTime])
+
+		event, err = reader.Read()
+		require.NoError(t, err)
+		require.Equal(t, int64(1700000000123), event.Properties[propertyTime])
+	})
+
+	t.Run("reports row errors and keeps reading", func(t *testing.T) {
+		mp := NewApiClient("token")
+		input := strings.Join([]string{
+			"action,user,at,id,amount,items,paid,tags,meta,renewal,internal,plan",
+			"purchase,user-1,2024-01-02 03:04:05,,nan-ish,,,,,,,",
+			"purchase,user-1,yesterday,,,,,,,,,",
+			"purchase,user-1",
+			",user-1,2024-01-02 03:04:05,,,,,,,,,",
+			"purchase,user-1,2024-01-02 03:04:05,,,three,,,,,,",
+			"purchase,user-1,2024-01-02 03:04:05,,,,,,,,,",
+		}, "\n")
+
+		reader, err := mp.NewCSVEventReader(strings.NewReader(input), mapping)
+		require.NoError(t, err)
+		events, rowErrors, err := reader.ReadAll()
+		require.NoError(t, err)
+		require.Len(t, events, 1)
+		require.Len(t, rowErrors, 5)
+
+		require.Equal(t, 2, rowErrors[0].Line)
+		require.Equal(t, "amount", rowErrors[0].Column)
+		require.Equal(t, 3, rowErrors[1].Line)
+		require.Equal(t, "at", rowErrors[1].Column)
+		require.Equal(t, 4, rowErrors[2].Line)
+		require.Equal(t, "", rowErrors[2].Column)
+		require.Equal(t, "action", rowErrors[3].Column)
+		require.Equal(t, "items", rowErrors[4].Column)
+		require.EqualError(t, rowErrors[4], `line 6, column "items": strconv.ParseInt: parsing "three": invalid syntax`)
+	})
+
+	t.Run("invalid mapping", func(t *testing.T) {
+		mp := NewApiClient("token")
+		for _, m := range []CSVMapping{
+			{DistinctIDColumn: "user"},
+			{EventName: "event", DistinctIDColumn: "missing"},
+			{EventName: "event", IgnoreColumns: []string{"missing"}},
+			{EventName: "event", Columns: map[string]CSVColumn{"missing": {Type: CSVInt}}},
+		} {
+			_, err := mp.NewCSVEventReader(strings.NewReader("user,plan\n"), m)
+			require.Error(t, err)
+		}
+
+		_, err := mp.NewCSVEventReader(strings.NewReader("user,user\n"), CSVMapping{EventName: "event"})
+		require.Error(t, err)
+	})
+}
+
+func TestImportCSV(t *testing.T) {
+	ctx := context.Background()
+	mp := NewApiClient("token")
+
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	var (
+		mu       sync.Mutex
+		imported []*Event
+	)
+	httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, importURL), func(req *http.Request) (*http.Response, error) {
+		events := decodeNdjsonRequest(t, req)
+		mu.Lock()
+		defer mu.Unlock()
+		imported = append(imported, events...)
+		return httpmock.NewStringResponse(http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(events))), nil
+	})
+
+	var builder strings.Builder
+	builder.WriteString("user,ts,count\n")
+	for i := 0; i < MaxImportEvents+5; i++ {
+		count := fmt.Sprint(i)
+		if i == 10 {
+			count = "ten"
+		}
+		fmt.Fprintf(&builder, "user-%d,%d,%s\n", i, time.Now().Unix(), count)
+	}
+
+	result, err := mp.ImportCSV(ctx, strings.NewReader(builder.String()), CSVMapping{
+		EventName:        "backfill",
+		DistinctIDColumn: "user",
+		TimeColumn:       "ts",
+		Columns:          map[string]CSVColumn{"count": {Type: CSVInt}},
+	}, ImportOptionsRecommend)
+	require.NoError(t, err)
+	require.Equal(t, MaxImportEvents+5, result.LinesRead)
+	require.Equal(t, MaxImportEvents+4, result.NumRecordsImported)
+	require.Equal(t, 2, httpmock.GetTotalCallCount())
+	require.Len(t, imported, MaxImportEvents+4)
+
+	require.Len(t, result.FailedLines, 1)
+	require.Equal(t, 12, result.FailedLines[0].Index)
+	require.Equal(t, "count", result.FailedLines[0].Field)
+}
@@ -0,0 +1,116 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrDeleteNotConfirmed is returned by DeleteProfiles when the deletion was not confirmed
+	ErrDeleteNotConfirmed = errors.New("profile deletion was not confirmed")
+	// ErrDeleteWithoutFilter is returned by DeleteProfiles when the query matches every profile and DeleteAll is not set
+	ErrDeleteWithoutFilter = errors.New("profile query has no filter, set DeleteAll to delete every profile")
+)
+
+type DeleteProfilesOptions struct {
+	// DryRun only reports the profiles that would be deleted
+	DryRun bool
+	// IgnoreAlias deletes the profile of the distinct id only instead of the profiles of its aliases, see PeopleDeleteProfile
+	IgnoreAlias bool
+	// ConfirmThreshold is the number of matching profiles above which Confirm must approve the deletion, 0 never asks
+	ConfirmThreshold int
+	// Confirm is called with the number of matching profiles, nothing is deleted unless it returns true
+	Confirm func(matched int) bool
+	// DeleteAll allows a query without a where, distinct ids or cohort filter that deletes every profile of the project
+	DeleteAll bool
+}
+
+type DeleteProfilesResult struct {
+	// Matched is the number of profiles matching the query
+	Matched int
+	// DistinctIDs are the profiles deleted, or the profiles that would be deleted in a dry run
+	DistinctIDs []string
+	// Deleted is the number of profiles deleted, 0 in a dry run
+	Deleted int
+}
+
+// DeleteProfiles deletes every profile matching the query
+// The distinct ids are deleted in batches of MaxPeopleEvents while the query is paged
+// Returns ErrDeleteWithoutFilter when the query has no filter and options.DeleteAll is not set
+// Returns ErrDeleteNotConfirmed without deleting anything when more than options.ConfirmThreshold profiles match and options.Confirm did not approve,
+// the profiles are counted while they are paged in case the query matches more profiles than the total reported by the api,
+// then Confirm is called with the number of profiles paged so far
+func (a *ApiClient) DeleteProfiles(ctx context.Context, query ProfileQuery, options DeleteProfilesOptions) (*DeleteProfilesResult, error) {
+	if !options.DryRun && !options.DeleteAll && !query.filtered() {
+		return nil, ErrDeleteWithoutFilter
+	}
+
+	profiles, err := a.QueryProfiles(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &DeleteProfilesResult{
+		Matched: profiles.Total(),
+	}
+	confirmed := options.DryRun || options.ConfirmThreshold <= 0
+	if !confirmed && result.Matched > options.ConfirmThreshold {
+		if options.Confirm == nil || !options.Confirm(result.Matched) {
+			return result, ErrDeleteNotConfirmed
+		}
+		confirmed = true
+	}
+
+	var (
+		batch   = a.NewPeopleBatch()
+		pending []string
+		matched int
+	)
+	flush := func() error {
+		if err := batch.Flush(ctx); err != nil {
+			return err
+		}
+		result.DistinctIDs = append(result.DistinctIDs, pending...)
+		result.Deleted += len(pending)
+		pending = nil
+		return nil
+	}
+
+	for profiles.Next() {
+		distinctID := profiles.Profile().DistinctID
+		if options.DryRun {
+			result.DistinctIDs = append(result.DistinctIDs, distinctID)
+			continue
+		}
+
+		// the deletes are held until the end while the deletion is not confirmed, so nothing is deleted if the threshold is passed
+		matched++
+		if !confirmed && matched > options.ConfirmThreshold {
+			result.Matched = matched
+			if options.Confirm == nil || !options.Confirm(matched) {
+				return result, ErrDeleteNotConfirmed
+			}
+			confirmed = true
+		}
+
+		// the distinct ids of the query are stored ids that were already redacted
+		batch.deleteStored(distinctID, distinctID, options.IgnoreAlias)
+		pending = append(pending, distinctID)
+		if confirmed && batch.Len() >= MaxPeopleEvents {
+			if err := flush(); err != nil {
+				return result, fmt.Errorf("failed to delete profiles: %w", err)
+			}
+		}
+	}
+	if err := profiles.Err(); err != nil {
+		return result, err
+	}
+
+	if batch.Len() > 0 {
+		if err := flush(); err != nil {
+			return result, fmt.Errorf("failed to delete profiles: %w", err)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,198 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteProfiles(t *testing.T) {
+	pages := [][]*Profile{
+		{{DistinctID: "test-1"}, {DistinctID: "test-2"}},
+		{{DistinctID: "test-3"}},
+	}
+	setupDeleteEndpoint := func(t *testing.T, client *ApiClient) *[]map[string]any {
+		var deleted []map[string]any
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, peopleBatchURL), func(req *http.Request) (*http.Response, error) {
+			var records []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&records))
+			deleted = append(deleted, records...)
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		})
+		return &deleted
+	}
+
+	t.Run("deletes every matching profile", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupEngageQueryEndpoint(t, mp, pages, func(page int, req *http.Request) {
+			require.Equal(t, `properties["test"] == true`, req.PostForm.Get("where"))
+		})
+		deleted := setupDeleteEndpoint(t, mp)
+
+		result, err := mp.DeleteProfiles(ctx, ProfileQuery{Where: `properties["test"] == true`}, DeleteProfilesOptions{IgnoreAlias: true})
+		require.NoError(t, err)
+		require.Equal(t, &DeleteProfilesResult{
+			Matched:     3,
+			DistinctIDs: []string{"test-1", "test-2", "test-3"},
+			Deleted:     3,
+		}, result)
+
+		require.Len(t, *deleted, 3)
+		for i, record := range *deleted {
+			require.Equal(t, fmt.Sprintf("test-%d", i+1), record["$distinct_id"])
+			require.Equal(t, "null", record["$delete"])
+			require.Equal(t, "true", record["$ignore_alias"])
+		}
+	})
+
+	t.Run("dry run does not delete", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupEngageQueryEndpoint(t, mp, pages, nil)
+		deleted := setupDeleteEndpoint(t, mp)
+
+		result, err := mp.DeleteProfiles(ctx, ProfileQuery{}, DeleteProfilesOptions{DryRun: true, ConfirmThreshold: 1})
+		require.NoError(t, err)
+		require.Equal(t, []string{"test-1", "test-2", "test-3"}, result.DistinctIDs)
+		require.Equal(t, 0, result.Deleted)
+		require.Empty(t, *deleted)
+	})
+
+	t.Run("confirmation threshold", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupEngageQueryEndpoint(t, mp, pages, nil)
+		deleted := setupDeleteEndpoint(t, mp)
+
+		result, err := mp.DeleteProfiles(ctx, ProfileQuery{}, DeleteProfilesOptions{ConfirmThreshold: 2, DeleteAll: true})
+		require.ErrorIs(t, err, ErrDeleteNotConfirmed)
+		require.Equal(t, 3, result.Matched)
+
+		var confirmed int
+		_, err = mp.DeleteProfiles(ctx, ProfileQuery{}, DeleteProfilesOptions{
+			DeleteAll:        true,
+			ConfirmThreshold: 2,
+			Confirm: func(matched int) bool {
+				confirmed = matched
+				return false
+			},
+		})
+		require.ErrorIs(t, err, ErrDeleteNotConfirmed)
+		require.Equal(t, 3, confirmed)
+		require.Empty(t, *deleted)
+	})
+
+	t.Run("a query without filter requires delete all", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupEngageQueryEndpoint(t, mp, pages, nil)
+		deleted := setupDeleteEndpoint(t, mp)
+
+		_, err := mp.DeleteProfiles(ctx, ProfileQuery{OutputProperties: []string{"plan"}}, DeleteProfilesOptions{})
+		require.ErrorIs(t, err, ErrDeleteWithoutFilter)
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+		require.Empty(t, *deleted)
+
+		result, err := mp.DeleteProfiles(ctx, ProfileQuery{}, DeleteProfilesOptions{DeleteAll: true})
+		require.NoError(t, err)
+		require.Equal(t, 3, result.Deleted)
+	})
+
+	// the total of the first page is lower than the profiles returned
+	setupUnderreportedQueryEndpoint := func(t *testing.T, client *ApiClient) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		requests := 0
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.queryEndpoint, engageQueryUrl), func(req *http.Request) (*http.Response, error) {
+			page := requests
+			requests++
+			return httpmock.NewJsonResponse(http.StatusOK, engageQueryResponse{
+				Page:      page,
+				PageSize:  2,
+				SessionID: "session",
+				Total:     1,
+				Results:   pages[page],
+			})
+		})
+	}
+
+	t.Run("confirmation threshold counts the streamed profiles", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupUnderreportedQueryEndpoint(t, mp)
+		deleted := setupDeleteEndpoint(t, mp)
+
+		result, err := mp.DeleteProfiles(ctx, ProfileQuery{Where: `properties["test"] == true`}, DeleteProfilesOptions{ConfirmThreshold: 1})
+		require.ErrorIs(t, err, ErrDeleteNotConfirmed)
+		require.Equal(t, 2, result.Matched)
+		require.Equal(t, 0, result.Deleted)
+		require.Empty(t, *deleted)
+
+		setupUnderreportedQueryEndpoint(t, mp)
+		var asked []int
+		result, err = mp.DeleteProfiles(ctx, ProfileQuery{Where: `properties["test"] == true`}, DeleteProfilesOptions{
+			ConfirmThreshold: 1,
+			Confirm: func(matched int) bool {
+				asked = append(asked, matched)
+				return false
+			},
+		})
+		require.ErrorIs(t, err, ErrDeleteNotConfirmed)
+		require.Equal(t, []int{2}, asked)
+		require.Empty(t, *deleted)
+	})
+
+	t.Run("the streamed profiles can be confirmed", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupUnderreportedQueryEndpoint(t, mp)
+		deleted := setupDeleteEndpoint(t, mp)
+
+		var asked []int
+		result, err := mp.DeleteProfiles(ctx, ProfileQuery{Where: `properties["test"] == true`}, DeleteProfilesOptions{
+			ConfirmThreshold: 1,
+			Confirm: func(matched int) bool {
+				asked = append(asked, matched)
+				return true
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int{2}, asked)
+		require.Equal(t, 2, result.Deleted)
+		require.Len(t, *deleted, 2)
+	})
+
+	t.Run("stored ids are not redacted again", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"), WithRedaction(RedactionPolicy{HashKeys: []string{propertyDistinctID}, HashSalt: "salt"}))
+		stored := mp.redactID("user-1")
+		setupEngageQueryEndpoint(t, mp, [][]*Profile{{{DistinctID: stored}}}, nil)
+		deleted := setupDeleteEndpoint(t, mp)
+
+		result, err := mp.DeleteProfiles(ctx, ProfileQuery{DistinctIDs: []string{stored}}, DeleteProfilesOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.Deleted)
+		require.Len(t, *deleted, 1)
+		require.Equal(t, stored, (*deleted)[0]["$distinct_id"])
+	})
+
+	t.Run("reports failed deletes", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupEngageQueryEndpoint(t, mp, pages, nil)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, peopleBatchURL), httpmock.NewStringResponder(http.StatusOK, "0"))
+
+		result, err := mp.DeleteProfiles(ctx, ProfileQuery{}, DeleteProfilesOptions{DeleteAll: true})
+		batchError := PeopleBatchError{}
+		require.ErrorAs(t, err, &batchError)
+		require.Len(t, batchError.Records, 3)
+		require.Equal(t, 0, result.Deleted)
+	})
+}
@@ -0,0 +1,29 @@
+package examples
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/mixpanel/mixpanel-go/v2"
+)
+
+func EventQueue() error {
+	config := mixpanel.DefaultQueueConfig()
+	config.FlushInterval = 10 * time.Second
+	config.OnError = func(events []*mixpanel.Event, err error) {
+		fmt.Printf("failed to send %d events: %s\n", len(events), err)
+	}
+
+	// fill in your token
+	mp := mixpanel.NewApiClient("token", mixpanel.WithEventQueue(config))
+
+	if err := mp.Enqueue(mp.NewEvent("test event", mixpanel.EmptyDistinctID, nil)); err != nil {
+		return err
+	}
+
+	// send the remaining events before exiting
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	return mp.Close(ctx)
+}
@@ -36,6 +36,7 @@ func (a *ApiClient) Export(ctx context.Context, fromDate, toDate time.Time, limi
 
 	httpResponse, err := a.doRequestBody(
 		ctx,
+		EndpointExport,
 		http.MethodGet,
 		a.dataEndpoint+exportUrl,
 		nil,
@@ -0,0 +1,172 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+const (
+	groupsBatchURL = "/groups#group-batch-update"
+)
+
+// GroupRecordError is the error of a single update of a GroupBatch
+type GroupRecordError struct {
+	// Index is the position of the update in the batch, in the order the updates were added
+	Index    int
+	GroupKey string
+	GroupID  string
+	// Operation is the group operation of the update, like $set or $union
+	Operation string
+	Err       error
+}
+
+func (e GroupRecordError) Error() string {
+	return fmt.Sprintf("%s for group %q %q failed: %s", e.Operation, e.GroupKey, e.GroupID, e.Err)
+}
+
+func (e GroupRecordError) Unwrap() error {
+	return e.Err
+}
+
+// GroupBatchError is returned by GroupBatch.Flush when one or more updates failed
+type GroupBatchError struct {
+	// Records are sorted by Index
+	Records []GroupRecordError
+}
+
+func (e GroupBatchError) Error() string {
+	return fmt.Sprintf("%d group updates failed, first error: %s", len(e.Records), e.Records[0].Error())
+}
+
+// Unwrap returns the first record error
+func (e GroupBatchError) Unwrap() error {
+	return e.Records[0]
+}
+
+// GroupBatch collects group updates for many groups and sends them together
+// The updates are sent in the order they were added, in requests of at most MaxPeopleEvents
+type GroupBatch struct {
+	profileUpdates
+	client *ApiClient
+}
+
+// NewGroupBatch returns an empty batch, it is safe to add updates from multiple goroutines
+// The updates use the PeopleUpdateOptions of the client until WithOptions is called
+func (a *ApiClient) NewGroupBatch() *GroupBatch {
+	b := &GroupBatch{client: a}
+	b.setOptions(a.peopleUpdateOptions)
+	return b
+}
+
+// WithOptions sets the modifiers of the updates added after the call
+func (b *GroupBatch) WithOptions(options PeopleUpdateOptions) *GroupBatch {
+	b.setOptions(options)
+	return b
+}
+
+func (b *GroupBatch) add(groupKey, groupID, operation string, payload any) *GroupBatch {
+	var err error
+	switch {
+	case groupKey == "":
+		err = errors.New("group key is empty")
+	case groupID == "":
+		err = errors.New("group id is empty")
+	}
+
+	b.profileUpdates.add(profileUpdate{
+		id:        groupID,
+		key:       groupKey,
+		operation: operation,
+		payload:   payload,
+		err:       err,
+	})
+	return b
+}
+
+// Set adds a $set update, see GroupSet
+func (b *GroupBatch) Set(groupKey, groupID string, set map[string]any) *GroupBatch {
+	return b.add(groupKey, groupID, profileOperationSet, groupSetPropertyPayload{
+		Token:            b.client.token,
+		GroupKey:         groupKey,
+		GroupId:          b.client.redactID(groupID),
+		Set:              b.client.redactProperties(set),
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// SetOnce adds a $set_once update, see GroupSetOnce
+func (b *GroupBatch) SetOnce(groupKey, groupID string, set map[string]any) *GroupBatch {
+	return b.add(groupKey, groupID, profileOperationSetOnce, groupSetOncePropertyPayload{
+		Token:            b.client.token,
+		GroupKey:         groupKey,
+		GroupId:          b.client.redactID(groupID),
+		SetOnce:          b.client.redactProperties(set),
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Unset adds an $unset update, see GroupDeleteProperty
+func (b *GroupBatch) Unset(groupKey, groupID string, unset []string) *GroupBatch {
+	return b.add(groupKey, groupID, profileOperationUnset, groupDeletePropertyPayload{
+		Token:            b.client.token,
+		GroupKey:         groupKey,
+		GroupId:          b.client.redactID(groupID),
+		Unset:            b.client.redactKeys(unset),
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Remove adds a $remove update, see GroupRemoveListProperty
+func (b *GroupBatch) Remove(groupKey, groupID string, remove map[string]any) *GroupBatch {
+	return b.add(groupKey, groupID, profileOperationRemove, groupRemoveListPropertyPayload{
+		Token:            b.client.token,
+		GroupKey:         groupKey,
+		GroupId:          b.client.redactID(groupID),
+		Remove:           b.client.redactProperties(remove),
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Union adds a $union update, see GroupUnionListProperty
+func (b *GroupBatch) Union(groupKey, groupID string, union map[string]any) *GroupBatch {
+	return b.add(groupKey, groupID, profileOperationUnion, groupUnionListPropertyPayload{
+		Token:            b.client.token,
+		GroupKey:         groupKey,
+		GroupId:          b.client.redactID(groupID),
+		Union:            b.client.redactProperties(union),
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Delete adds a $delete update, see GroupDelete
+func (b *GroupBatch) Delete(groupKey, groupID string) *GroupBatch {
+	return b.add(groupKey, groupID, profileOperationDelete, groupDeletePayload{
+		Token:            b.client.token,
+		GroupKey:         groupKey,
+		GroupId:          b.client.redactID(groupID),
+		Delete:           "null",
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Flush sends the updates and empties the batch
+// Updates without a group key or id are not sent, every update of a request that failed is reported in a GroupBatchError
+func (b *GroupBatch) Flush(ctx context.Context) error {
+	failed := b.client.flushProfileUpdates(ctx, b.take(), EndpointGroups, groupsBatchURL)
+	if len(failed) == 0 {
+		return nil
+	}
+
+	records := make([]GroupRecordError, len(failed))
+	for i, f := range failed {
+		records[i] = GroupRecordError{
+			Index:     f.index,
+			GroupKey:  f.update.key,
+			GroupID:   f.update.id,
+			Operation: f.update.operation,
+			Err:       f.err,
+		}
+	}
+	return GroupBatchError{Records: records}
+}
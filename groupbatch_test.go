@@ -0,0 +1,96 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupBatch(t *testing.T) {
+	setupGroupsEndpoint := func(t *testing.T, client *ApiClient, responder func(records []map[string]any) string) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, groupsBatchURL), func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "application/json", req.Header.Get("content-type"))
+			var records []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&records))
+			return httpmock.NewStringResponse(http.StatusOK, responder(records)), nil
+		})
+	}
+
+	t.Run("sends mixed operations in order", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+
+		var sent []map[string]any
+		setupGroupsEndpoint(t, mp, func(records []map[string]any) string {
+			sent = records
+			return "1"
+		})
+
+		batch := mp.NewGroupBatch().
+			Set("company", "1", map[string]any{"plan": "pro"}).
+			SetOnce("company", "1", map[string]any{"created": "2024-01-01"}).
+			Unset("company", "2", []string{"plan"}).
+			Remove("company", "2", map[string]any{"tags": "old"}).
+			Union("team", "3", map[string]any{"tags": []string{"new"}}).
+			Delete("team", "4")
+		require.Equal(t, 6, batch.Len())
+
+		require.NoError(t, batch.Flush(ctx))
+		require.Equal(t, 0, batch.Len())
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+
+		require.Len(t, sent, 6)
+		for i, operation := range []string{"$set", "$set_once", "$unset", "$remove", "$union", "$delete"} {
+			require.Contains(t, sent[i], operation)
+			require.Equal(t, "token", sent[i]["$token"])
+		}
+		require.Equal(t, "team", sent[4]["$group_key"])
+		require.Equal(t, "3", sent[4]["$group_id"])
+	})
+
+	t.Run("reports the records that failed", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+
+		var sizes []int
+		setupGroupsEndpoint(t, mp, func(records []map[string]any) string {
+			sizes = append(sizes, len(records))
+			if len(sizes) == 1 {
+				return "0"
+			}
+			return "1"
+		})
+
+		batch := mp.NewGroupBatch()
+		for i := 0; i < MaxPeopleEvents+1; i++ {
+			batch.Set("company", fmt.Sprint(i), map[string]any{"synced": true})
+		}
+		batch.Set("", "1", nil).Delete("company", "")
+
+		err := batch.Flush(ctx)
+		batchError := GroupBatchError{}
+		require.ErrorAs(t, err, &batchError)
+		require.Equal(t, []int{MaxPeopleEvents, 1}, sizes)
+
+		require.Len(t, batchError.Records, MaxPeopleEvents+2)
+		require.Equal(t, GroupRecordError{
+			Index:     0,
+			GroupKey:  "company",
+			GroupID:   "0",
+			Operation: "$set",
+			Err:       batchError.Records[0].Err,
+		}, batchError.Records[0])
+		require.EqualError(t, batchError.Records[0].Err, "api return code 0")
+		require.EqualError(t, batchError.Records[MaxPeopleEvents].Err, "group key is empty")
+		require.EqualError(t, batchError.Records[MaxPeopleEvents+1].Err, "group id is empty")
+		require.Equal(t, "$delete", batchError.Records[MaxPeopleEvents+1].Operation)
+	})
+}
@@ -1,6 +1,7 @@
 package mixpanel
 
 import (
+	"bufio"
 	"bytes"
 	"compress/gzip"
 	"context"
@@ -12,6 +13,7 @@ import (
 	"net/http/httputil"
 	"net/url"
 	"strconv"
+	"sync"
 )
 
 type MpCompression int
@@ -65,6 +67,12 @@ func applicationJsonHeader() httpOptions {
 	}
 }
 
+func applicationNdjsonHeader() httpOptions {
+	return func(req *http.Request) {
+		req.Header.Set(contentTypeHeader, contentTypeApplicationNdjson)
+	}
+}
+
 func applicationFormData() httpOptions {
 	return func(req *http.Request) {
 		req.Header.Set(contentTypeHeader, contentTypeApplicationForm)
@@ -130,7 +138,7 @@ type debugHttpCalls struct {
 	writer io.Writer
 }
 
-func (d *debugHttpCalls) writeDebug(r *http.Request) error {
+func (d *debugHttpCalls) writeDebug(r *http.Request, redaction *redactor) error {
 	if d.writer == nil {
 		return nil
 	}
@@ -145,7 +153,7 @@ func (d *debugHttpCalls) writeDebug(r *http.Request) error {
 		return fmt.Errorf("failed to write start header %w", err)
 	}
 
-	_, err = d.writer.Write(requestDump)
+	_, err = d.writer.Write(redaction.dump(requestDump))
 	if err != nil {
 		return fmt.Errorf("failed to write debug_http payload %w", err)
 	}
@@ -177,6 +185,8 @@ type requestPostPayloadType int
 const (
 	jsonPayload requestPostPayloadType = iota
 	formPayload
+	// ndjsonPayload is one json event per line, only supported by the streaming body
+	ndjsonPayload
 )
 
 func makeRequestBody(body any, bodyType requestPostPayloadType, compress MpCompression) (*bytes.Reader, error) {
@@ -215,6 +225,195 @@ func requestBodyJsonCompress(jsonPayload []byte, compress MpCompression) (*bytes
 	}
 }
 
+// streamBufferSize is the size of the writes to the request body pipe
+const streamBufferSize = 32 * 1024
+
+var (
+	gzipWriterPool = sync.Pool{
+		New: func() any {
+			return gzip.NewWriter(io.Discard)
+		},
+	}
+	bufferedWriterPool = sync.Pool{
+		New: func() any {
+			return bufio.NewWriterSize(io.Discard, streamBufferSize)
+		},
+	}
+)
+
+// bodyEncodingError is returned by the http client when a streamed body could not be encoded
+// it is not a network error so the request is neither retried nor spooled
+type bodyEncodingError struct {
+	err error
+}
+
+func (e bodyEncodingError) Error() string {
+	return fmt.Sprintf("failed to create http body: %s", e.err)
+}
+
+func (e bodyEncodingError) Unwrap() error {
+	return e.err
+}
+
+func isBodyEncodingError(err error) bool {
+	var encodingError bodyEncodingError
+	return errors.As(err, &encodingError)
+}
+
+// eventsStream creates request bodies that encode the events while the request is sent
+// instead of holding the json and the compressed json in memory
+type eventsStream struct {
+	events   []*Event
+	payload  requestPostPayloadType
+	compress MpCompression
+
+	mu     sync.Mutex
+	bodies []*streamingBody
+}
+
+func newEventsStream(events []*Event, payload requestPostPayloadType, compress MpCompression) *eventsStream {
+	return &eventsStream{
+		events:   events,
+		payload:  payload,
+		compress: compress,
+	}
+}
+
+// body sets the request body, GetBody creates a new stream for retries
+func (s *eventsStream) body() httpOptions {
+	return func(req *http.Request) {
+		req.GetBody = func() (io.ReadCloser, error) {
+			return s.newBody(), nil
+		}
+		req.Body = s.newBody()
+		req.ContentLength = -1
+	}
+}
+
+func (s *eventsStream) newBody() io.ReadCloser {
+	body := newStreamingBody(s.events, s.payload, s.compress)
+	s.mu.Lock()
+	s.bodies = append(s.bodies, body)
+	s.mu.Unlock()
+	return body
+}
+
+// close stops the encoders that are still running, the events are not read after close returns
+// The http client can return before the body was read, when the server responds early
+func (s *eventsStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, body := range s.bodies {
+		body.Close()
+	}
+}
+
+type streamingBody struct {
+	*io.PipeReader
+	done chan struct{}
+}
+
+func newStreamingBody(events []*Event, payload requestPostPayloadType, compress MpCompression) *streamingBody {
+	reader, writer := io.Pipe()
+	body := &streamingBody{
+		PipeReader: reader,
+		done:       make(chan struct{}),
+	}
+	go func() {
+		defer close(body.done)
+		writer.CloseWithError(writeEvents(writer, events, payload, compress))
+	}()
+	return body
+}
+
+// Close waits for the encoder to stop
+func (b *streamingBody) Close() error {
+	err := b.PipeReader.Close()
+	<-b.done
+	return err
+}
+
+// writeEvents writes the same json array as makeRequestBody, or ndjson, one event at a time
+func writeEvents(w io.Writer, events []*Event, payload requestPostPayloadType, compress MpCompression) error {
+	buffered := bufferedWriterPool.Get().(*bufio.Writer)
+	buffered.Reset(w)
+	defer func() {
+		buffered.Reset(io.Discard)
+		bufferedWriterPool.Put(buffered)
+	}()
+
+	var out io.Writer
+	var gzipWriter *gzip.Writer
+	switch compress {
+	case None:
+		out = buffered
+	case Gzip:
+		gzipWriter = gzipWriterPool.Get().(*gzip.Writer)
+		gzipWriter.Reset(buffered)
+		defer func() {
+			gzipWriter.Reset(io.Discard)
+			gzipWriterPool.Put(gzipWriter)
+		}()
+		out = gzipWriter
+	default:
+		return fmt.Errorf("unknown compression type: %d", compress)
+	}
+
+	if err := encodeEvents(out, events, payload); err != nil {
+		return err
+	}
+	if gzipWriter != nil {
+		if err := gzipWriter.Close(); err != nil {
+			return fmt.Errorf("failed to close gzip writer: %w", err)
+		}
+	}
+	return buffered.Flush()
+}
+
+var (
+	jsonArrayStart     = []byte("[")
+	jsonArrayEnd       = []byte("]")
+	jsonArraySeparator = []byte(",")
+	newLine            = []byte("\n")
+)
+
+func encodeEvents(w io.Writer, events []*Event, payload requestPostPayloadType) error {
+	start, separator, end := jsonArrayStart, jsonArraySeparator, jsonArrayEnd
+	switch payload {
+	case jsonPayload:
+	case ndjsonPayload:
+		start, separator, end = nil, newLine, newLine
+	default:
+		return fmt.Errorf("unsupported body type: %d", payload)
+	}
+
+	// events are encoded one at a time into the same buffer
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+
+	if _, err := w.Write(start); err != nil {
+		return err
+	}
+	for i, event := range events {
+		if i > 0 {
+			if _, err := w.Write(separator); err != nil {
+				return err
+			}
+		}
+
+		buffer.Reset()
+		if err := encoder.Encode(event); err != nil {
+			return bodyEncodingError{err: err}
+		}
+		// Encode adds a new line that json.Marshal does not
+		if _, err := w.Write(bytes.TrimSuffix(buffer.Bytes(), newLine)); err != nil {
+			return err
+		}
+	}
+	_, err := w.Write(end)
+	return err
+}
+
 func requestForm(jsonPayload []byte) (*bytes.Reader, error) {
 	form := url.Values{}
 	form.Add("data", string(jsonPayload))
@@ -223,6 +422,7 @@ func requestForm(jsonPayload []byte) (*bytes.Reader, error) {
 
 func (m *ApiClient) doRequestBody(
 	ctx context.Context,
+	endpoint EndpointFamily,
 	method string,
 	requestUrl string,
 	body io.Reader,
@@ -237,20 +437,27 @@ func (m *ApiClient) doRequestBody(
 		o(request)
 	}
 
-	if err := m.debugHttpCall.writeDebug(request); err != nil {
+	if err := m.debugHttpCall.writeDebug(request, m.redaction); err != nil {
+		if request.Body != nil {
+			request.Body.Close()
+		}
 		return nil, fmt.Errorf("failed to write debug_http call: %w", err)
 	}
 
+	if m.retryPolicy.appliesTo(endpoint) {
+		return m.doWithRetry(ctx, request)
+	}
 	return m.client.Do(request)
 }
 
-func (m *ApiClient) doPeopleRequest(ctx context.Context, body any, u string) error {
+func (m *ApiClient) doPeopleRequest(ctx context.Context, endpoint EndpointFamily, body any, u string) error {
 	requestBody, err := makeRequestBody(body, jsonPayload, None)
 	if err != nil {
 		return fmt.Errorf("failed to create request body: %w", err)
 	}
 	response, err := m.doRequestBody(
 		ctx,
+		endpoint,
 		http.MethodPost,
 		m.apiEndpoint+u,
 		requestBody,
@@ -275,6 +482,7 @@ func (m *ApiClient) doIdentifyRequest(ctx context.Context, body any, u string, o
 	requestOptions := append([]httpOptions{acceptPlainText(), applicationFormData()}, option...)
 	response, err := m.doRequestBody(
 		ctx,
+		EndpointIdentity,
 		http.MethodPost,
 		m.apiEndpoint+u,
 		requestBody,
@@ -1,8 +1,13 @@
 package mixpanel
 
 import (
+	"compress/gzip"
+	"context"
+	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 
@@ -113,3 +118,127 @@ func TestProcessPeopleRequestResponse(t *testing.T) {
 		require.Equal(t, http.StatusTeapot, httpErr.Status)
 	})
 }
+
+func makeLargeEvents(mp *ApiClient, count int) []*Event {
+	events := make([]*Event, count)
+	for i := range events {
+		properties := make(map[string]any, 20)
+		for p := 0; p < 20; p++ {
+			properties[fmt.Sprintf("property_%d", p)] = strings.Repeat("value", 10)
+		}
+		events[i] = mp.NewEvent(fmt.Sprintf("event_%d", i), fmt.Sprintf("user_%d", i), properties)
+	}
+	return events
+}
+
+func TestStreamingBody(t *testing.T) {
+	mp := NewApiClient("token")
+	events := makeLargeEvents(mp, 100)
+
+	t.Run("same body as makeRequestBody", func(t *testing.T) {
+		expected, err := makeRequestBody(events, jsonPayload, None)
+		require.NoError(t, err)
+		expectedData, err := io.ReadAll(expected)
+		require.NoError(t, err)
+
+		data, err := io.ReadAll(newStreamingBody(events, jsonPayload, None))
+		require.NoError(t, err)
+		require.Equal(t, string(expectedData), string(data))
+
+		gzipReader, err := gzip.NewReader(newStreamingBody(events, jsonPayload, Gzip))
+		require.NoError(t, err)
+		data, err = io.ReadAll(gzipReader)
+		require.NoError(t, err)
+		require.Equal(t, string(expectedData), string(data))
+	})
+
+	t.Run("body can be recreated", func(t *testing.T) {
+		request, err := http.NewRequest(http.MethodPost, "https://localhost", nil)
+		require.NoError(t, err)
+		stream := newEventsStream(events, jsonPayload, Gzip)
+		defer stream.close()
+		stream.body()(request)
+		require.Equal(t, int64(-1), request.ContentLength)
+
+		first, err := io.ReadAll(request.Body)
+		require.NoError(t, err)
+		body, err := request.GetBody()
+		require.NoError(t, err)
+		second, err := io.ReadAll(body)
+		require.NoError(t, err)
+		require.Equal(t, first, second)
+	})
+
+	t.Run("encoding errors are not network errors", func(t *testing.T) {
+		invalid := []*Event{mp.NewEvent("event", "user", map[string]any{"nan": math.NaN()})}
+		_, err := io.ReadAll(newStreamingBody(invalid, jsonPayload, Gzip))
+		require.True(t, isBodyEncodingError(err))
+
+		err = &url.Error{Op: "Post", URL: "https://localhost", Err: err}
+		require.False(t, isSpoolable(err))
+		policy := DefaultRetryPolicy()
+		require.False(t, policy.shouldRetry(context.Background(), nil, err))
+	})
+
+	t.Run("unknown compression", func(t *testing.T) {
+		_, err := io.ReadAll(newStreamingBody(events, jsonPayload, MpCompression(3)))
+		require.Error(t, err)
+	})
+
+	t.Run("closing the body stops the encoder", func(t *testing.T) {
+		body := newStreamingBody(makeLargeEvents(mp, 1_000), jsonPayload, None)
+		buffer := make([]byte, 10)
+		_, err := body.Read(buffer)
+		require.NoError(t, err)
+		require.NoError(t, body.Close())
+
+		select {
+		case <-body.done:
+		default:
+			require.Fail(t, "encoder is still running after close")
+		}
+	})
+
+	t.Run("closing the stream stops every body", func(t *testing.T) {
+		stream := newEventsStream(makeLargeEvents(mp, 1_000), jsonPayload, Gzip)
+		request, err := http.NewRequest(http.MethodPost, "https://localhost", nil)
+		require.NoError(t, err)
+		stream.body()(request)
+		_, err = request.GetBody()
+		require.NoError(t, err)
+
+		stream.close()
+		require.Len(t, stream.bodies, 2)
+		for _, body := range stream.bodies {
+			<-body.done
+		}
+	})
+}
+
+func BenchmarkRequestBody(b *testing.B) {
+	mp := NewApiClient("token")
+	events := makeLargeEvents(mp, MaxImportEvents)
+
+	b.Run("buffered", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			body, err := makeRequestBody(events, jsonPayload, Gzip)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := io.Copy(io.Discard, body); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("streaming", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			body := newStreamingBody(events, jsonPayload, Gzip)
+			if _, err := io.Copy(io.Discard, body); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
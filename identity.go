@@ -1,13 +1,107 @@
 package mixpanel
 
-import "context"
+import (
+	"context"
+	"errors"
+	"fmt"
+)
 
 const (
 	identityEndpoint = "/track#create-identity"
 	aliasEndpoint    = "/track#identity-create-alias"
 	mergeEndpoint    = "/import"
+
+	propertyDeviceID = "$device_id"
+	propertyUserID   = "$user_id"
+
+	// deviceIDPrefix is the distinct_id mixpanel gives to events that only have a $device_id
+	deviceIDPrefix = "$device:"
 )
 
+// IdentityMode is the ID Merge api of the project
+// https://docs.mixpanel.com/docs/tracking-methods/id-management
+type IdentityMode int
+
+const (
+	// IdentityModeOriginal is the Original ID Merge api, Alias and Merge are available
+	IdentityModeOriginal IdentityMode = iota
+	// IdentityModeSimplified is the Simplified ID Merge api, users are identified by the $device_id and $user_id of the events
+	IdentityModeSimplified
+)
+
+func (m IdentityMode) String() string {
+	switch m {
+	case IdentityModeOriginal:
+		return "original"
+	case IdentityModeSimplified:
+		return "simplified"
+	default:
+		return "unknown"
+	}
+}
+
+// ErrIdentityModeUnsupported is returned by the identity calls that the identity mode of the client does not support
+var ErrIdentityModeUnsupported = errors.New("not supported by the identity mode")
+
+// WithIdentityMode declares the ID Merge api of the project, the default is IdentityModeOriginal
+func WithIdentityMode(mode IdentityMode) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.identityMode = mode
+	}
+}
+
+func (a *ApiClient) requireIdentityMode(call string, mode IdentityMode) error {
+	if a.identityMode != mode {
+		return fmt.Errorf("%s is only available with %s ID Merge, the project uses %s ID Merge: %w", call, mode, a.identityMode, ErrIdentityModeUnsupported)
+	}
+	return nil
+}
+
+type identifyPayload struct {
+	Event      string             `json:"event"`
+	Properties identifyProperties `json:"properties"`
+}
+
+type identifyProperties struct {
+	Token string `json:"token"`
+
+	// Original ID Merge
+	IdentifiedID string `json:"$identified_id,omitempty"`
+	AnonID       string `json:"$anon_id,omitempty"`
+
+	// Simplified ID Merge
+	DistinctID string `json:"distinct_id,omitempty"`
+	DeviceID   string `json:"$device_id,omitempty"`
+	UserID     string `json:"$user_id,omitempty"`
+}
+
+// Identify links the anonymous id of a user before they logged in to their user id
+// With IdentityModeSimplified the $identify event has the anonymous id as $device_id and the user id as $user_id
+// https://developer.mixpanel.com/reference/create-identity
+func (a *ApiClient) Identify(ctx context.Context, anonID, userID string) error {
+	if anonID == "" || userID == "" {
+		return errors.New("anon id and user id are required")
+	}
+
+	payload := &identifyPayload{
+		Event: "$identify",
+		Properties: identifyProperties{
+			Token: a.token,
+		},
+	}
+	anonID, userID = a.redactID(anonID), a.redactID(userID)
+	if a.identityMode == IdentityModeSimplified {
+		payload.Properties.DistinctID = userID
+		payload.Properties.DeviceID = anonID
+		payload.Properties.UserID = userID
+	} else {
+		payload.Properties.IdentifiedID = userID
+		payload.Properties.AnonID = anonID
+	}
+
+	return a.doIdentifyRequest(ctx, payload, identityEndpoint)
+}
+
 type aliasPayload struct {
 	Event      string          `json:"event"`
 	Properties aliasProperties `json:"properties"`
@@ -19,12 +113,17 @@ type aliasProperties struct {
 }
 
 // https://developer.mixpanel.com/reference/identity-create-alias
+// Returns ErrIdentityModeUnsupported with IdentityModeSimplified
 func (a *ApiClient) Alias(ctx context.Context, aliasID, distinctID string) error {
+	if err := a.requireIdentityMode("alias", IdentityModeOriginal); err != nil {
+		return err
+	}
+
 	payload := &aliasPayload{
 		Event: "$create_alias",
 		Properties: aliasProperties{
-			DistinctId: distinctID,
-			Alias:      aliasID,
+			DistinctId: a.redactID(distinctID),
+			Alias:      a.redactID(aliasID),
 			Token:      a.token,
 		},
 	}
@@ -43,11 +142,16 @@ type mergeProperties struct {
 
 // https://developer.mixpanel.com/reference/identity-merge
 // must provide api secret
+// Returns ErrIdentityModeUnsupported with IdentityModeSimplified
 func (a *ApiClient) Merge(ctx context.Context, distinctID1, distinctID2 string) error {
+	if err := a.requireIdentityMode("merge", IdentityModeOriginal); err != nil {
+		return err
+	}
+
 	payload := &mergePayload{
 		Event: "$merge",
 		Properties: mergeProperties{
-			DistinctId: []string{distinctID1, distinctID2},
+			DistinctId: []string{a.redactID(distinctID1), a.redactID(distinctID2)},
 		},
 	}
 
@@ -71,3 +71,93 @@ func TestMerge(t *testing.T) {
 
 	require.NoError(t, mp.Merge(ctx, "distinct-id-1", "distinct-id-2"))
 }
+
+func TestIdentify(t *testing.T) {
+	t.Run("original id merge", func(t *testing.T) {
+		ctx := context.Background()
+
+		mp := NewApiClient("token")
+		setupIdentityEndpoint(t, mp, identityEndpoint, func(req *http.Request) {}, func(body io.Reader) {
+			var payload map[string]any
+			require.NoError(t, json.NewDecoder(body).Decode(&payload))
+
+			require.Equal(t, "$identify", payload["event"])
+			require.Equal(t, map[string]any{
+				"token":          "token",
+				"$identified_id": "user-id",
+				"$anon_id":       "anon-id",
+			}, payload["properties"])
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("1")),
+		})
+
+		require.NoError(t, mp.Identify(ctx, "anon-id", "user-id"))
+	})
+
+	t.Run("simplified id merge", func(t *testing.T) {
+		ctx := context.Background()
+
+		mp := NewApiClient("token", WithIdentityMode(IdentityModeSimplified))
+		setupIdentityEndpoint(t, mp, identityEndpoint, func(req *http.Request) {}, func(body io.Reader) {
+			var payload map[string]any
+			require.NoError(t, json.NewDecoder(body).Decode(&payload))
+
+			require.Equal(t, "$identify", payload["event"])
+			require.Equal(t, map[string]any{
+				"token":       "token",
+				"distinct_id": "user-id",
+				"$device_id":  "anon-id",
+				"$user_id":    "user-id",
+			}, payload["properties"])
+		}, &http.Response{
+			StatusCode: http.StatusOK,
+			Body:       io.NopCloser(strings.NewReader("1")),
+		})
+
+		require.NoError(t, mp.Identify(ctx, "anon-id", "user-id"))
+	})
+
+	t.Run("requires both ids", func(t *testing.T) {
+		mp := NewApiClient("token")
+		require.Error(t, mp.Identify(context.Background(), "", "user-id"))
+		require.Error(t, mp.Identify(context.Background(), "anon-id", ""))
+	})
+}
+
+func TestSimplifiedIdentityMode(t *testing.T) {
+	t.Run("alias and merge fail fast", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithIdentityMode(IdentityModeSimplified))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		err := mp.Alias(ctx, "alias-id", "distinct-id")
+		require.ErrorIs(t, err, ErrIdentityModeUnsupported)
+		require.Contains(t, err.Error(), "simplified")
+
+		require.ErrorIs(t, mp.Merge(ctx, "distinct-id-1", "distinct-id-2"), ErrIdentityModeUnsupported)
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("identified events", func(t *testing.T) {
+		mp := NewApiClient("token")
+
+		event := mp.NewIdentifiedEvent("login", "device-1", "user-1", nil)
+		require.Equal(t, "user-1", event.Properties[propertyDistinctID])
+		require.Equal(t, "device-1", event.Properties[propertyDeviceID])
+		require.Equal(t, "user-1", event.Properties[propertyUserID])
+
+		event = mp.NewIdentifiedEvent("page view", "device-1", "", nil)
+		require.Equal(t, "$device:device-1", event.Properties[propertyDistinctID])
+		require.Equal(t, "device-1", event.Properties[propertyDeviceID])
+		require.NotContains(t, event.Properties, propertyUserID)
+
+		event = mp.NewEvent("page view", "user-1", nil)
+		event.AddDeviceID("device-2")
+		event.AddUserID("user-1")
+		require.Equal(t, "device-2", event.Properties[propertyDeviceID])
+		require.Equal(t, "user-1", event.Properties[propertyUserID])
+	})
+}
@@ -0,0 +1,292 @@
+package mixpanel
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ImportProgress is reported by ImportStream after every batch
+type ImportProgress struct {
+	LinesRead          int
+	NumRecordsImported int
+	NumFailedLines     int
+}
+
+type ImportStreamResult struct {
+	// LinesRead is the number of ndjson lines read by ImportStream, or of csv rows without the header read by ImportCSV
+	LinesRead          int
+	NumRecordsImported int
+	// FailedLines has the line number, starting at 1, in the Index of every line that could not be parsed or failed validation
+	FailedLines []ImportFailedRecords
+}
+
+// ImportStream reads newline delimited json events from r and imports them in batches of at most MaxImportEvents and options.MaxBatchBytes,
+// sending up to options.Concurrency batches at a time
+// Lines that are not valid events are reported in FailedLines and the rest of the stream is still imported
+// Returns a BatchError if any of the batches failed, the Offset of the ChunkError is the line of the first event of the batch
+func (a *ApiClient) ImportStream(ctx context.Context, r io.Reader, options ImportOptions) (*ImportStreamResult, error) {
+	return a.importSource(ctx, &ndjsonSource{
+		client: a,
+		reader: bufio.NewReader(r),
+	}, options)
+}
+
+// ImportStreamFile calls ImportStream with the content of the file, files ending in .gz are decompressed
+func (a *ApiClient) ImportStreamFile(ctx context.Context, path string, options ImportOptions) (*ImportStreamResult, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer file.Close()
+
+	var r io.Reader = file
+	if strings.HasSuffix(path, ".gz") {
+		gzipReader, err := gzip.NewReader(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read gzip file: %w", err)
+		}
+		defer gzipReader.Close()
+		r = gzipReader
+	}
+
+	return a.ImportStream(ctx, r, options)
+}
+
+// sourceRecord is a line read from an eventSource
+// event is nil for blank lines and for lines that failed with err
+type sourceRecord struct {
+	event *Event
+	line  int
+	size  int
+	err   error
+}
+
+type eventSource interface {
+	// next returns io.EOF after the last record
+	next() (sourceRecord, error)
+}
+
+// importSource imports the events of the source in batches, see ImportStream
+func (a *ApiClient) importSource(ctx context.Context, source eventSource, options ImportOptions) (*ImportStreamResult, error) {
+	maxBytes := options.MaxBatchBytes
+	if maxBytes <= 0 {
+		maxBytes = MaxImportBytes
+	}
+	concurrency := options.Concurrency
+	if concurrency <= 0 {
+		concurrency = defaultBatchConcurrency
+	}
+
+	stream := &importStream{
+		client:    a,
+		options:   options,
+		semaphore: make(chan struct{}, concurrency),
+	}
+
+	var (
+		batch []*Event
+		lines []int
+		size  int
+	)
+	flush := func() {
+		if len(batch) > 0 {
+			stream.send(ctx, batch, lines)
+		}
+		batch, lines, size = nil, nil, 0
+	}
+
+	var readErr error
+	for ctx.Err() == nil {
+		record, err := source.next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			readErr = err
+			break
+		}
+
+		stream.readLine()
+		if record.err != nil {
+			stream.failLine(record.line, record.err)
+			continue
+		}
+		if record.event == nil {
+			continue
+		}
+
+		if len(batch) >= MaxImportEvents || (len(batch) > 0 && size+record.size > maxBytes) {
+			flush()
+		}
+		batch = append(batch, record.event)
+		lines = append(lines, record.line)
+		size += record.size
+	}
+	if ctx.Err() == nil {
+		flush()
+	}
+	stream.wg.Wait()
+
+	result := stream.result()
+	switch {
+	case readErr != nil:
+		return result, readErr
+	case ctx.Err() != nil:
+		return result, ctx.Err()
+	case len(stream.chunkErrors) > 0:
+		sort.Slice(stream.chunkErrors, func(i, j int) bool {
+			return stream.chunkErrors[i].Offset < stream.chunkErrors[j].Offset
+		})
+		return result, BatchError{Chunks: stream.chunkErrors}
+	default:
+		return result, nil
+	}
+}
+
+type ndjsonSource struct {
+	client *ApiClient
+	reader *bufio.Reader
+	line   int
+	// err is returned by the next call when the last line was read with an error
+	err error
+}
+
+func (s *ndjsonSource) next() (sourceRecord, error) {
+	if s.err != nil {
+		return sourceRecord{}, s.err
+	}
+
+	data, err := s.reader.ReadBytes('\n')
+	if err != nil {
+		if !errors.Is(err, io.EOF) {
+			err = fmt.Errorf("failed to read line %d: %w", s.line+1, err)
+		}
+		if len(data) == 0 {
+			return sourceRecord{}, err
+		}
+		s.err = err
+	}
+
+	s.line++
+	event, err := s.client.parseEventLine(data)
+	return sourceRecord{
+		event: event,
+		line:  s.line,
+		size:  len(data),
+		err:   err,
+	}, nil
+}
+
+// parseEventLine returns nil if the line is blank
+func (a *ApiClient) parseEventLine(data []byte) (*Event, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, nil
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid json: %w", err)
+	}
+	return a.NewEventFromJson(raw)
+}
+
+type importStream struct {
+	client    *ApiClient
+	options   ImportOptions
+	semaphore chan struct{}
+	wg        sync.WaitGroup
+
+	// imported is shared with importBisect and guards the other fields
+	imported    importAllResult
+	linesRead   int
+	chunkErrors []ChunkError
+}
+
+func (s *importStream) readLine() {
+	s.imported.mu.Lock()
+	s.linesRead++
+	s.imported.mu.Unlock()
+}
+
+func (s *importStream) failLine(line int, err error) {
+	field := "line"
+	rowError := CSVRowError{}
+	if errors.As(err, &rowError) && rowError.Column != "" {
+		field = rowError.Column
+	}
+
+	s.imported.mu.Lock()
+	s.imported.FailedImportRecords = append(s.imported.FailedImportRecords, ImportFailedRecords{
+		Index:   line,
+		Field:   field,
+		Message: err.Error(),
+	})
+	s.imported.mu.Unlock()
+}
+
+// send imports the batch in the background, blocking while concurrency batches are in flight
+func (s *importStream) send(ctx context.Context, batch []*Event, lines []int) {
+	s.semaphore <- struct{}{}
+	s.wg.Add(1)
+	go func() {
+		defer func() {
+			<-s.semaphore
+			s.wg.Done()
+		}()
+
+		intercepted, indexes := s.client.intercept(ctx, batch)
+		interceptedLines := make([]int, len(intercepted))
+		for i := range intercepted {
+			interceptedLines[i] = lines[originalIndex(indexes, i)]
+		}
+
+		// nothing is sent when the interceptors dropped every event
+		var err error
+		if len(intercepted) > 0 {
+			err = s.client.importBisect(ctx, intercepted, 0, interceptedLines, ndjsonPayload, s.options, &s.imported)
+		}
+
+		s.imported.mu.Lock()
+		defer s.imported.mu.Unlock()
+		if err != nil {
+			s.chunkErrors = append(s.chunkErrors, ChunkError{
+				Offset: lines[0],
+				Size:   len(batch),
+				Err:    err,
+			})
+		}
+		if s.options.OnProgress != nil {
+			s.options.OnProgress(ImportProgress{
+				LinesRead:          s.linesRead,
+				NumRecordsImported: s.imported.NumRecordsImported,
+				NumFailedLines:     len(s.imported.FailedImportRecords),
+			})
+		}
+	}()
+}
+
+func (s *importStream) result() *ImportStreamResult {
+	s.imported.mu.Lock()
+	defer s.imported.mu.Unlock()
+
+	failed := append([]ImportFailedRecords(nil), s.imported.FailedImportRecords...)
+	sort.SliceStable(failed, func(i, j int) bool {
+		return failed[i].Index < failed[j].Index
+	})
+	return &ImportStreamResult{
+		LinesRead:          s.linesRead,
+		NumRecordsImported: s.imported.NumRecordsImported,
+		FailedLines:        failed,
+	}
+}
@@ -0,0 +1,212 @@
+package mixpanel
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeNdjsonRequest decodes the events sent as ndjson to the import endpoint
+func decodeNdjsonRequest(t *testing.T, req *http.Request) []*Event {
+	require.Equal(t, contentTypeApplicationNdjson, req.Header.Get(contentTypeHeader))
+
+	var reader io.Reader = req.Body
+	if req.Header.Get("content-encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(req.Body)
+		require.NoError(t, err)
+		reader = gzipReader
+	}
+
+	var events []*Event
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(nil, MaxImportBytes)
+	for scanner.Scan() {
+		var event Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		events = append(events, &event)
+	}
+	require.NoError(t, scanner.Err())
+	return events
+}
+
+func makeNdjson(t *testing.T, mp *ApiClient, count int) string {
+	var builder strings.Builder
+	for _, event := range makeEvents(mp, count) {
+		data, err := json.Marshal(event)
+		require.NoError(t, err)
+		builder.Write(data)
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+func TestImportStream(t *testing.T) {
+	setupImportStreamEndpoint := func(t *testing.T, client *ApiClient, responder func(events []*Event) (int, string)) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var mu sync.Mutex
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, importURL), func(req *http.Request) (*http.Response, error) {
+			events := decodeNdjsonRequest(t, req)
+			mu.Lock()
+			defer mu.Unlock()
+			status, body := responder(events)
+			return httpmock.NewStringResponse(status, body), nil
+		})
+	}
+	imported := func(events []*Event) (int, string) {
+		return http.StatusOK, fmt.Sprintf(`{"code": 200,"num_records_imported": %d,"status": 1}`, len(events))
+	}
+
+	t.Run("imports every line in batches", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		setupImportStreamEndpoint(t, mp, func(events []*Event) (int, string) {
+			require.LessOrEqual(t, len(events), MaxImportEvents)
+			return imported(events)
+		})
+
+		var progress []ImportProgress
+		options := ImportOptionsRecommend
+		options.Concurrency = 1
+		options.OnProgress = func(p ImportProgress) {
+			progress = append(progress, p)
+		}
+
+		result, err := mp.ImportStream(ctx, strings.NewReader(makeNdjson(t, mp, MaxImportEvents+10)), options)
+		require.NoError(t, err)
+		require.Equal(t, &ImportStreamResult{
+			LinesRead:          MaxImportEvents + 10,
+			NumRecordsImported: MaxImportEvents + 10,
+			FailedLines:        nil,
+		}, result)
+		require.Equal(t, 2, httpmock.GetTotalCallCount())
+
+		require.Len(t, progress, 2)
+		require.Equal(t, MaxImportEvents, progress[0].NumRecordsImported)
+		require.Equal(t, ImportProgress{LinesRead: MaxImportEvents + 10, NumRecordsImported: MaxImportEvents + 10}, progress[1])
+	})
+
+	t.Run("does not send batches the interceptors dropped", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithEventInterceptors(func(ctx context.Context, event *Event) (*Event, error) {
+			return nil, nil
+		}))
+		setupImportStreamEndpoint(t, mp, imported)
+
+		result, err := mp.ImportStream(ctx, strings.NewReader(makeNdjson(t, mp, 3)), ImportOptionsRecommend)
+		require.NoError(t, err)
+		require.Equal(t, &ImportStreamResult{LinesRead: 3}, result)
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+
+		_, err = mp.Import(ctx, makeEvents(mp, 3), ImportOptionsRecommend)
+		require.NoError(t, err)
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("reports the line of invalid events", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		setupImportStreamEndpoint(t, mp, func(events []*Event) (int, string) {
+			require.Len(t, events, 3)
+			return http.StatusBadRequest, `{
+				"code": 400,
+				"error": "some data points in the request failed validation",
+				"num_records_imported": 2,
+				"failed_records": [{"index": 2, "field": "properties.time", "message": "'properties.time' is invalid"}]
+			}`
+		})
+
+		lines := strings.Split(strings.TrimSpace(makeNdjson(t, mp, 3)), "\n")
+		input := strings.Join([]string{
+			lines[0],
+			"",
+			"{not json",
+			lines[1],
+			`{"event": 1, "properties": {}}`,
+			lines[2],
+		}, "\n")
+
+		result, err := mp.ImportStream(ctx, strings.NewReader(input), ImportOptionsRecommend)
+		batchError := BatchError{}
+		require.ErrorAs(t, err, &batchError)
+		require.Equal(t, 1, batchError.Chunks[0].Offset)
+
+		require.Equal(t, 6, result.LinesRead)
+		require.Equal(t, 2, result.NumRecordsImported)
+		require.Len(t, result.FailedLines, 3)
+		require.Equal(t, 3, result.FailedLines[0].Index)
+		require.Equal(t, "line", result.FailedLines[0].Field)
+		require.Equal(t, 5, result.FailedLines[1].Index)
+		require.Equal(t, 6, result.FailedLines[2].Index)
+		require.Equal(t, "properties.time", result.FailedLines[2].Field)
+	})
+
+	t.Run("splits batches by size and bisects batches that are too large", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		var sizes []int
+		setupImportStreamEndpoint(t, mp, func(events []*Event) (int, string) {
+			sizes = append(sizes, len(events))
+			if len(events) > 2 {
+				return http.StatusRequestEntityTooLarge, `{"code": 413, "error": "request too large", "status": 0}`
+			}
+			return imported(events)
+		})
+
+		input := makeNdjson(t, mp, 8)
+		options := ImportOptionsRecommend
+		options.Concurrency = 1
+		options.MaxBatchBytes = len(input)/2 + 1
+
+		result, err := mp.ImportStream(ctx, strings.NewReader(input), options)
+		require.NoError(t, err)
+		require.Equal(t, 8, result.NumRecordsImported)
+		require.Equal(t, []int{4, 2, 2, 4, 2, 2}, sizes)
+	})
+
+	t.Run("stops when the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		mp := NewApiClient("token")
+		setupImportStreamEndpoint(t, mp, imported)
+
+		_, err := mp.ImportStream(ctx, strings.NewReader(makeNdjson(t, mp, 10)), ImportOptionsRecommend)
+		require.ErrorIs(t, err, context.Canceled)
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("imports gzipped files", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		setupImportStreamEndpoint(t, mp, imported)
+
+		path := filepath.Join(t.TempDir(), "events.ndjson.gz")
+		file, err := os.Create(path)
+		require.NoError(t, err)
+		gzipWriter := gzip.NewWriter(file)
+		_, err = gzipWriter.Write([]byte(makeNdjson(t, mp, 10)))
+		require.NoError(t, err)
+		require.NoError(t, gzipWriter.Close())
+		require.NoError(t, file.Close())
+
+		result, err := mp.ImportStreamFile(ctx, path, ImportOptionsRecommend)
+		require.NoError(t, err)
+		require.Equal(t, 10, result.NumRecordsImported)
+
+		_, err = mp.ImportStreamFile(ctx, filepath.Join(t.TempDir(), "missing.ndjson"), ImportOptionsRecommend)
+		require.Error(t, err)
+	})
+}
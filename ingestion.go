@@ -1,13 +1,18 @@
 package mixpanel
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
+	"regexp"
 	"strconv"
+	"strings"
 )
 
 const (
@@ -16,11 +21,18 @@ const (
 	MaxTrackEvents  = 2_000
 	MaxImportEvents = 2_000
 
+	// https://developer.mixpanel.com/reference/import-events#rate-limits
+
+	MaxImportBytes = 10 * 1024 * 1024
+
 	// https://developer.mixpanel.com/reference/user-profile-limits
 
 	MaxPeopleEvents = 2_000
 )
 
+// decimalPattern is the json number grammar
+var decimalPattern = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][+-]?[0-9]+)?$`)
+
 const (
 	trackURL  = "/track"
 	importURL = "/import"
@@ -48,31 +60,130 @@ const (
 // For server side we recommend Import func
 // more info here: https://developer.mixpanel.com/reference/track-event#when-to-use-track-vs-import
 func (m *ApiClient) Track(ctx context.Context, events []*Event) error {
+	_, err := m.TrackWithOptions(ctx, events, TrackOptions{})
+	return err
+}
+
+type TrackOptions struct {
+	Compression MpCompression
+	// IP geolocates the events without an ip property using the ip of the request
+	IP bool
+	// Strict validates the events and returns an ImportFailedValidationError with the failed records
+	Strict bool
+}
+
+type TrackResult struct {
+	Code int
+	// NumRecordsImported is only reported by the api in strict mode
+	NumRecordsImported int
+	Status             any
+}
+
+type trackResponse struct {
+	Code                int                   `json:"code"`
+	ApiError            string                `json:"error"`
+	Status              any                   `json:"status"`
+	NumRecordsImported  int                   `json:"num_records_imported"`
+	FailedImportRecords []ImportFailedRecords `json:"failed_records"`
+}
+
+// TrackWithOptions calls the Track endpoint with the options
+// https://developer.mixpanel.com/reference/track-event
+func (m *ApiClient) TrackWithOptions(ctx context.Context, events []*Event, options TrackOptions) (*TrackResult, error) {
+	intercepted, indexes := m.intercept(ctx, events)
+	if err := m.addInsertIDs(intercepted); err != nil {
+		return nil, err
+	}
+
+	send, validIndexes, err := m.validateBeforeSend(m.redactEvents(intercepted), false)
+	if err != nil {
+		return nil, remapFailedRecords(err, indexes)
+	}
+	if len(send) == 0 && len(events) > 0 {
+		return &TrackResult{Code: http.StatusOK, Status: 1}, nil
+	}
+
+	result, err := m.track(ctx, send, options)
+	if err != nil {
+		err = remapFailedRecords(remapFailedRecords(err, validIndexes), indexes)
+	}
+	return result, m.spoolOnFailure(spoolTrackEndpoint, options.Strict, send, err)
+}
+
+func (m *ApiClient) track(ctx context.Context, events []*Event, options TrackOptions) (*TrackResult, error) {
 	if len(events) > MaxTrackEvents {
-		return fmt.Errorf("max track events is %d", MaxTrackEvents)
+		return nil, fmt.Errorf("max track events is %d", MaxTrackEvents)
 	}
 
 	query := url.Values{}
 	query.Add("verbose", "1")
+	if options.IP {
+		query.Add("ip", "1")
+	}
+	if options.Strict {
+		query.Add("strict", "1")
+	}
 
-	requestBody, err := makeRequestBody(events, jsonPayload, None)
+	requestBody, err := makeRequestBody(events, jsonPayload, options.Compression)
 	if err != nil {
-		return fmt.Errorf("failed to create request body: %w", err)
+		return nil, fmt.Errorf("failed to create request body: %w", err)
+	}
+
+	httpOptions := []httpOptions{addQueryParams(query), acceptPlainText(), applicationJsonHeader()}
+	if options.Compression == Gzip {
+		httpOptions = append(httpOptions, gzipHeader())
 	}
 
 	response, err := m.doRequestBody(
 		ctx,
+		EndpointTrack,
 		http.MethodPost,
 		m.apiEndpoint+trackURL,
 		requestBody,
-		addQueryParams(query), acceptPlainText(), applicationJsonHeader(),
+		httpOptions...,
 	)
 	if err != nil {
-		return fmt.Errorf("failed to track event: %w", err)
+		return nil, fmt.Errorf("failed to track event: %w", err)
 	}
 	defer response.Body.Close()
 
-	return parseVerboseApiError(response.Body)
+	if response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= http.StatusInternalServerError {
+		return nil, newHttpError(response.StatusCode, response.Body)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	var r trackResponse
+	if err := json.Unmarshal(body, &r); err != nil {
+		return nil, fmt.Errorf("failed to json decode response body: %w", err)
+	}
+
+	if len(r.FailedImportRecords) > 0 {
+		return nil, ImportFailedValidationError{
+			Code:                response.StatusCode,
+			ApiError:            r.ApiError,
+			Status:              r.Status,
+			NumRecordsImported:  r.NumRecordsImported,
+			FailedImportRecords: r.FailedImportRecords,
+		}
+	}
+	// verbose responses have a numeric status, 0 is a failure
+	if _, ok := r.Status.(float64); ok {
+		if err := parseVerboseApiError(bytes.NewReader(body)); err != nil {
+			return nil, err
+		}
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, VerboseError{ApiError: r.ApiError, Status: apiErrorStatus}
+	}
+
+	return &TrackResult{
+		Code:               response.StatusCode,
+		NumRecordsImported: r.NumRecordsImported,
+		Status:             r.Status,
+	}, nil
 }
 
 type ImportFailedValidationError struct {
@@ -97,6 +208,15 @@ func (e ImportFailedValidationError) Error() string {
 type ImportOptions struct {
 	Strict      bool
 	Compression MpCompression
+	// Concurrency is the max number of requests ImportAll, ImportStream and ImportCSV send at the same time
+	// Import and WithEventQueue ignore it
+	Concurrency int
+	// MaxBatchBytes is the max size of the uncompressed json of a batch sent by ImportAll, ImportStream and ImportCSV,
+	// defaults to MaxImportBytes, Import and WithEventQueue ignore it
+	MaxBatchBytes int
+	// OnProgress is called by ImportStream and ImportCSV after every batch
+	// Import, ImportAll and WithEventQueue ignore it
+	OnProgress func(progress ImportProgress)
 }
 
 var ImportOptionsRecommend = ImportOptions{
@@ -124,10 +244,61 @@ func (e ImportGenericError) Error() string {
 	return e.ApiError
 }
 
+// newImportGenericError decodes the error of the response, Code is always the http status code
+// A response that is not json, like the html page of a proxy, is kept as the ApiError
+func newImportGenericError(statusCode int, body io.Reader) ImportGenericError {
+	var g ImportGenericError
+	data, err := io.ReadAll(body)
+	if err == nil {
+		err = json.Unmarshal(data, &g)
+	}
+	if err != nil {
+		g = ImportGenericError{ApiError: strings.TrimSpace(string(data))}
+	}
+	if g.ApiError == "" {
+		g.ApiError = http.StatusText(statusCode)
+	}
+	g.Code = statusCode
+	return g
+}
+
 // Import calls the Import api
 // https://developer.mixpanel.com/reference/import-events
 // Need to provide project id a service account, project token or api secret to the client
 func (a *ApiClient) Import(ctx context.Context, events []*Event, options ImportOptions) (*ImportSuccess, error) {
+	intercepted, indexes := a.intercept(ctx, events)
+	if len(intercepted) == 0 && len(events) > 0 {
+		return &ImportSuccess{Code: http.StatusOK}, nil
+	}
+
+	success, err := a.importIntercepted(ctx, intercepted, jsonPayload, options)
+	return success, remapFailedRecords(err, indexes)
+}
+
+// importIntercepted imports events the interceptors already ran on
+func (a *ApiClient) importIntercepted(ctx context.Context, events []*Event, payload requestPostPayloadType, options ImportOptions) (*ImportSuccess, error) {
+	if err := a.addInsertIDs(events); err != nil {
+		return nil, err
+	}
+
+	send, indexes, err := a.validateBeforeSend(a.redactEvents(events), true)
+	if err != nil {
+		return nil, err
+	}
+	if len(send) == 0 {
+		return &ImportSuccess{Code: http.StatusOK}, nil
+	}
+
+	success, err := a.importEvents(ctx, send, payload, options)
+	if err != nil {
+		err = remapFailedRecords(err, indexes)
+		return nil, a.spoolOnFailure(spoolImportEndpoint, options.Strict, send, err)
+	}
+	return success, a.spoolOnFailure(spoolImportEndpoint, options.Strict, send, nil)
+}
+
+// importEvents sends the events as a json array or as ndjson
+func (a *ApiClient) importEvents(ctx context.Context, events []*Event, payload requestPostPayloadType, options ImportOptions) (*ImportSuccess, error) {
 	if len(events) > MaxImportEvents {
 		return nil, fmt.Errorf("max import events is %d", MaxImportEvents)
 	}
@@ -144,21 +315,29 @@ func (a *ApiClient) Import(ctx context.Context, events []*Event, options ImportO
 	}
 	values.Add("verbose", "1")
 
-	body, err := makeRequestBody(events, jsonPayload, options.Compression)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request body: %w", err)
+	if options.Compression != None && options.Compression != Gzip {
+		return nil, fmt.Errorf("failed to create request body: unknown compression type: %d", options.Compression)
+	}
+
+	contentType := applicationJsonHeader()
+	if payload == ndjsonPayload {
+		contentType = applicationNdjsonHeader()
 	}
 
-	httpOptions := []httpOptions{applicationJsonHeader(), addQueryParams(values), acceptJson(), a.importAuthOptions()}
+	stream := newEventsStream(events, payload, options.Compression)
+	defer stream.close()
+
+	httpOptions := []httpOptions{stream.body(), contentType, addQueryParams(values), acceptJson(), a.importAuthOptions()}
 	if options.Compression == Gzip {
 		httpOptions = append(httpOptions, gzipHeader())
 	}
 
 	httpResponse, err := a.doRequestBody(
 		ctx,
+		EndpointImport,
 		http.MethodPost,
 		a.apiEndpoint+importURL,
-		body,
+		nil,
 		httpOptions...,
 	)
 	if err != nil {
@@ -180,19 +359,11 @@ func (a *ApiClient) Import(ctx context.Context, events []*Event, options ImportO
 		}
 		return nil, g
 	case http.StatusUnauthorized, http.StatusRequestEntityTooLarge:
-		var g ImportGenericError
-		if err := json.NewDecoder(httpResponse.Body).Decode(&g); err != nil {
-			return nil, fmt.Errorf("failed to json decode response body: %w", err)
-		}
-		return nil, g
+		return nil, newImportGenericError(httpResponse.StatusCode, httpResponse.Body)
 	case http.StatusTooManyRequests:
-		var g ImportRateLimitError
-		if err := json.NewDecoder(httpResponse.Body).Decode(&g); err != nil {
-			return nil, fmt.Errorf("failed to json decode response body: %w", err)
-		}
-		return nil, g
+		return nil, ImportRateLimitError{ImportGenericError: newImportGenericError(httpResponse.StatusCode, httpResponse.Body)}
 	default:
-		return nil, fmt.Errorf("unexpected status code: %d", httpResponse.StatusCode)
+		return nil, newHttpError(httpResponse.StatusCode, httpResponse.Body)
 	}
 }
 
@@ -281,7 +452,7 @@ type peopleSetPayload struct {
 	Token      string         `json:"$token"`
 	DistinctID string         `json:"$distinct_id"`
 	Set        map[string]any `json:"$set"`
-	IP         string         `json:"$ip,omitempty"`
+	profileModifiers
 }
 
 // PeopleSet calls the User Set Property API
@@ -293,22 +464,23 @@ func (a *ApiClient) PeopleSet(ctx context.Context, people []*PeopleProperties) e
 
 	payloads := make([]peopleSetPayload, len(people))
 	for i, p := range people {
+		redacted := a.redactPeople(p)
 		payloads[i] = peopleSetPayload{
-			Token:      a.token,
-			DistinctID: p.DistinctID,
-			Set:        p.Properties,
-			IP:         p.shouldGeoLookupIp(),
+			Token:            a.token,
+			DistinctID:       redacted.DistinctID,
+			Set:              redacted.Properties,
+			profileModifiers: a.peopleUpdateOptions.peopleModifiers(p, a.redaction),
 		}
 	}
 
-	return a.doPeopleRequest(ctx, payloads, peopleSetURL)
+	return a.doPeopleRequest(ctx, EndpointPeople, payloads, peopleSetURL)
 }
 
 type peopleSetOncePayload struct {
 	Token      string         `json:"$token"`
 	DistinctID string         `json:"$distinct_id"`
 	SetOnce    map[string]any `json:"$set_once"`
-	IP         string         `json:"$ip,omitempty"`
+	profileModifiers
 }
 
 // PeopleSetOnce calls the User Set Property Once API
@@ -320,39 +492,127 @@ func (a *ApiClient) PeopleSetOnce(ctx context.Context, people []*PeoplePropertie
 
 	payloads := make([]peopleSetOncePayload, len(people))
 	for i, p := range people {
+		redacted := a.redactPeople(p)
 		payloads[i] = peopleSetOncePayload{
-			Token:      a.token,
-			DistinctID: p.DistinctID,
-			SetOnce:    p.Properties,
-			IP:         p.shouldGeoLookupIp(),
+			Token:            a.token,
+			DistinctID:       redacted.DistinctID,
+			SetOnce:          redacted.Properties,
+			profileModifiers: a.peopleUpdateOptions.peopleModifiers(p, a.redaction),
 		}
 	}
-	return a.doPeopleRequest(ctx, payloads, peopleSetOnceURL)
+	return a.doPeopleRequest(ctx, EndpointPeople, payloads, peopleSetOnceURL)
 }
 
 type peopleNumericalAddPayload struct {
-	Token      string         `json:"$token"`
-	DistinctID string         `json:"$distinct_id"`
-	Add        map[string]int `json:"$add"`
+	Token      string                 `json:"$token"`
+	DistinctID string                 `json:"$distinct_id"`
+	Add        map[string]json.Number `json:"$add"`
+	profileModifiers
 }
 
 // PeopleIncrement calls the User Increment Numerical Property API
 // https://developer.mixpanel.com/reference/profile-numerical-add
 func (a *ApiClient) PeopleIncrement(ctx context.Context, distinctID string, add map[string]int) error {
+	return a.PeopleAdd(ctx, distinctID, intIncrements(add))
+}
+
+// PeopleAdd calls the User Increment Numerical Property API with any numeric values, negative values decrement the property
+// The values can be any int, uint or float type, a json.Number or a decimal string, they are sent without losing precision
+// https://developer.mixpanel.com/reference/profile-numerical-add
+func (a *ApiClient) PeopleAdd(ctx context.Context, distinctID string, add map[string]any) error {
+	numbers, err := numericIncrements(a.redactIncrements(add))
+	if err != nil {
+		return err
+	}
+
 	payload := []peopleNumericalAddPayload{
 		{
-			Token:      a.token,
-			DistinctID: distinctID,
-			Add:        add,
+			Token:            a.token,
+			DistinctID:       a.redactID(distinctID),
+			Add:              numbers,
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, peopleIncrementUrl)
+	return a.doPeopleRequest(ctx, EndpointPeopleIncrement, payload, peopleIncrementUrl)
+}
+
+func intIncrements(add map[string]int) map[string]any {
+	increments := make(map[string]any, len(add))
+	for key, value := range add {
+		increments[key] = value
+	}
+	return increments
+}
+
+func numericIncrements(add map[string]any) (map[string]json.Number, error) {
+	numbers := make(map[string]json.Number, len(add))
+	for key, value := range add {
+		number, err := toJsonNumber(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid increment for %q: %w", key, err)
+		}
+		numbers[key] = number
+	}
+	return numbers, nil
+}
+
+// toJsonNumber formats the value with the shortest representation that parses back to the same value
+func toJsonNumber(value any) (json.Number, error) {
+	switch v := value.(type) {
+	case int:
+		return json.Number(strconv.FormatInt(int64(v), 10)), nil
+	case int8:
+		return json.Number(strconv.FormatInt(int64(v), 10)), nil
+	case int16:
+		return json.Number(strconv.FormatInt(int64(v), 10)), nil
+	case int32:
+		return json.Number(strconv.FormatInt(int64(v), 10)), nil
+	case int64:
+		return json.Number(strconv.FormatInt(v, 10)), nil
+	case uint:
+		return json.Number(strconv.FormatUint(uint64(v), 10)), nil
+	case uint8:
+		return json.Number(strconv.FormatUint(uint64(v), 10)), nil
+	case uint16:
+		return json.Number(strconv.FormatUint(uint64(v), 10)), nil
+	case uint32:
+		return json.Number(strconv.FormatUint(uint64(v), 10)), nil
+	case uint64:
+		return json.Number(strconv.FormatUint(v, 10)), nil
+	case float32:
+		return formatFloat(float64(v), 32)
+	case float64:
+		return formatFloat(v, 64)
+	case json.Number:
+		return parseDecimal(string(v))
+	case string:
+		return parseDecimal(v)
+	default:
+		return "", fmt.Errorf("expected a number, got %T", value)
+	}
+}
+
+func formatFloat(f float64, bitSize int) (json.Number, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("%v is not a valid json number", f)
+	}
+	return json.Number(strconv.FormatFloat(f, 'f', -1, bitSize)), nil
+}
+
+// parseDecimal keeps the digits of the string as is, it only checks it is a valid json number
+func parseDecimal(s string) (json.Number, error) {
+	s = strings.TrimSpace(s)
+	if !decimalPattern.MatchString(s) {
+		return "", fmt.Errorf("%q is not a decimal number", s)
+	}
+	return json.Number(s), nil
 }
 
 type peopleUnionPayload struct {
 	Token      string         `json:"$token"`
 	DistinctID string         `json:"$distinct_id"`
 	Union      map[string]any `json:"$union"`
+	profileModifiers
 }
 
 // PeopleUnionProperty calls User Union To List Property API
@@ -360,18 +620,20 @@ type peopleUnionPayload struct {
 func (a *ApiClient) PeopleUnionProperty(ctx context.Context, distinctID string, union map[string]any) error {
 	payload := []peopleUnionPayload{
 		{
-			Token:      a.token,
-			DistinctID: distinctID,
-			Union:      union,
+			Token:            a.token,
+			DistinctID:       a.redactID(distinctID),
+			Union:            a.redactProperties(union),
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, peopleUnionToListUrl)
+	return a.doPeopleRequest(ctx, EndpointPeople, payload, peopleUnionToListUrl)
 }
 
 type peopleAppendListPayload struct {
 	Token      string         `json:"$token"`
 	DistinctID string         `json:"$distinct_id"`
 	Append     map[string]any `json:"$append"`
+	profileModifiers
 }
 
 // PeopleAppend calls the Increment Numerical Property
@@ -379,18 +641,20 @@ type peopleAppendListPayload struct {
 func (a *ApiClient) PeopleAppendListProperty(ctx context.Context, distinctID string, append map[string]any) error {
 	payload := []peopleAppendListPayload{
 		{
-			Token:      a.token,
-			DistinctID: distinctID,
-			Append:     append,
+			Token:            a.token,
+			DistinctID:       a.redactID(distinctID),
+			Append:           a.redactProperties(append),
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, peopleAppendToListUrl)
+	return a.doPeopleRequest(ctx, EndpointPeopleIncrement, payload, peopleAppendToListUrl)
 }
 
 type peopleListRemovePayload struct {
 	Token      string         `json:"$token"`
 	DistinctID string         `json:"$distinct_id"`
 	Remove     map[string]any `json:"$remove"`
+	profileModifiers
 }
 
 // PeopleRemoveListProperty calls the User Remove from List Property API
@@ -398,18 +662,20 @@ type peopleListRemovePayload struct {
 func (a *ApiClient) PeopleRemoveListProperty(ctx context.Context, distinctID string, remove map[string]any) error {
 	payload := []peopleListRemovePayload{
 		{
-			Token:      a.token,
-			DistinctID: distinctID,
-			Remove:     remove,
+			Token:            a.token,
+			DistinctID:       a.redactID(distinctID),
+			Remove:           a.redactProperties(remove),
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, peopleRemoveFromListUrl)
+	return a.doPeopleRequest(ctx, EndpointPeople, payload, peopleRemoveFromListUrl)
 }
 
 type peopleDeletePropertyPayload struct {
 	Token      string   `json:"$token"`
 	DistinctID string   `json:"$distinct_id"`
 	Unset      []string `json:"$unset"`
+	profileModifiers
 }
 
 // PeopleDeleteProperty calls the User Delete Property API
@@ -417,12 +683,13 @@ type peopleDeletePropertyPayload struct {
 func (a *ApiClient) PeopleDeleteProperty(ctx context.Context, distinctID string, unset []string) error {
 	payload := []peopleDeletePropertyPayload{
 		{
-			Token:      a.token,
-			DistinctID: distinctID,
-			Unset:      unset,
+			Token:            a.token,
+			DistinctID:       a.redactID(distinctID),
+			Unset:            a.redactKeys(unset),
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, peopleDeletePropertyUrl)
+	return a.doPeopleRequest(ctx, EndpointPeople, payload, peopleDeletePropertyUrl)
 }
 
 type peopleDeleteProfilePayload struct {
@@ -430,6 +697,7 @@ type peopleDeleteProfilePayload struct {
 	DistinctID  string `json:"$distinct_id"`
 	Delete      string `json:"$delete"`
 	IgnoreAlias string `json:"$ignore_alias"`
+	profileModifiers
 }
 
 // PeopleDeleteProfile calls the User Delete Profile API
@@ -437,13 +705,14 @@ type peopleDeleteProfilePayload struct {
 func (a *ApiClient) PeopleDeleteProfile(ctx context.Context, distinctID string, ignoreAlias bool) error {
 	payload := []peopleDeleteProfilePayload{
 		{
-			Token:       a.token,
-			DistinctID:  distinctID,
-			Delete:      "null", // The $delete object value is ignored - the profile is determined by the $distinct_id from the request itself.
-			IgnoreAlias: strconv.FormatBool(ignoreAlias),
+			Token:            a.token,
+			DistinctID:       a.redactID(distinctID),
+			Delete:           "null", // The $delete object value is ignored - the profile is determined by the $distinct_id from the request itself.
+			IgnoreAlias:      strconv.FormatBool(ignoreAlias),
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, peopleDeleteProfileUrl)
+	return a.doPeopleRequest(ctx, EndpointPeople, payload, peopleDeleteProfileUrl)
 }
 
 type groupSetPropertyPayload struct {
@@ -451,6 +720,7 @@ type groupSetPropertyPayload struct {
 	GroupKey string         `json:"$group_key"`
 	GroupId  string         `json:"$group_id"`
 	Set      map[string]any `json:"$set"`
+	profileModifiers
 }
 
 // GroupUpdateProperty calls the Group Update Property API
@@ -458,13 +728,14 @@ type groupSetPropertyPayload struct {
 func (a *ApiClient) GroupSet(ctx context.Context, groupKey, groupID string, set map[string]any) error {
 	payload := []groupSetPropertyPayload{
 		{
-			Token:    a.token,
-			GroupKey: groupKey,
-			GroupId:  groupID,
-			Set:      set,
+			Token:            a.token,
+			GroupKey:         groupKey,
+			GroupId:          a.redactID(groupID),
+			Set:              a.redactProperties(set),
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, groupSetUrl)
+	return a.doPeopleRequest(ctx, EndpointGroups, payload, groupSetUrl)
 }
 
 type groupSetOncePropertyPayload struct {
@@ -472,6 +743,7 @@ type groupSetOncePropertyPayload struct {
 	GroupKey string         `json:"$group_key"`
 	GroupId  string         `json:"$group_id"`
 	SetOnce  map[string]any `json:"$set_once"`
+	profileModifiers
 }
 
 // GroupSetOnce calls the Group Set Property Once API
@@ -479,13 +751,14 @@ type groupSetOncePropertyPayload struct {
 func (a *ApiClient) GroupSetOnce(ctx context.Context, groupKey, groupID string, set map[string]any) error {
 	payload := []groupSetOncePropertyPayload{
 		{
-			Token:    a.token,
-			GroupKey: groupKey,
-			GroupId:  groupID,
-			SetOnce:  set,
+			Token:            a.token,
+			GroupKey:         groupKey,
+			GroupId:          a.redactID(groupID),
+			SetOnce:          a.redactProperties(set),
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, groupsSetOnceUrl)
+	return a.doPeopleRequest(ctx, EndpointGroups, payload, groupsSetOnceUrl)
 }
 
 type groupDeletePropertyPayload struct {
@@ -493,6 +766,7 @@ type groupDeletePropertyPayload struct {
 	GroupKey string   `json:"$group_key"`
 	GroupId  string   `json:"$group_id"`
 	Unset    []string `json:"$unset"`
+	profileModifiers
 }
 
 // GroupDeleteProperty calls the group delete property API
@@ -500,13 +774,14 @@ type groupDeletePropertyPayload struct {
 func (a *ApiClient) GroupDeleteProperty(ctx context.Context, groupKey, groupID string, unset []string) error {
 	payload := []groupDeletePropertyPayload{
 		{
-			Token:    a.token,
-			GroupKey: groupKey,
-			GroupId:  groupID,
-			Unset:    unset,
+			Token:            a.token,
+			GroupKey:         groupKey,
+			GroupId:          a.redactID(groupID),
+			Unset:            a.redactKeys(unset),
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, groupsDeletePropertyUrl)
+	return a.doPeopleRequest(ctx, EndpointGroups, payload, groupsDeletePropertyUrl)
 }
 
 type groupRemoveListPropertyPayload struct {
@@ -514,6 +789,7 @@ type groupRemoveListPropertyPayload struct {
 	GroupKey string         `json:"$group_key"`
 	GroupId  string         `json:"$group_id"`
 	Remove   map[string]any `json:"$remove"`
+	profileModifiers
 }
 
 // GroupRemoveListProperty calls the Groups Remove from List Property API
@@ -521,13 +797,14 @@ type groupRemoveListPropertyPayload struct {
 func (a *ApiClient) GroupRemoveListProperty(ctx context.Context, groupKey, groupID string, remove map[string]any) error {
 	payload := []groupRemoveListPropertyPayload{
 		{
-			Token:    a.token,
-			GroupKey: groupKey,
-			GroupId:  groupID,
-			Remove:   remove,
+			Token:            a.token,
+			GroupKey:         groupKey,
+			GroupId:          a.redactID(groupID),
+			Remove:           a.redactProperties(remove),
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, groupsRemoveFromListPropertyUrl)
+	return a.doPeopleRequest(ctx, EndpointGroups, payload, groupsRemoveFromListPropertyUrl)
 }
 
 type groupUnionListPropertyPayload struct {
@@ -535,6 +812,7 @@ type groupUnionListPropertyPayload struct {
 	GroupKey string         `json:"$group_key"`
 	GroupId  string         `json:"$group_id"`
 	Union    map[string]any `json:"$union"`
+	profileModifiers
 }
 
 // GroupUnionListProperty calls the Groups Remove from Union Property API
@@ -542,13 +820,14 @@ type groupUnionListPropertyPayload struct {
 func (a *ApiClient) GroupUnionListProperty(ctx context.Context, groupKey, groupID string, union map[string]any) error {
 	payload := []groupUnionListPropertyPayload{
 		{
-			Token:    a.token,
-			GroupKey: groupKey,
-			GroupId:  groupID,
-			Union:    union,
+			Token:            a.token,
+			GroupKey:         groupKey,
+			GroupId:          a.redactID(groupID),
+			Union:            a.redactProperties(union),
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
-	return a.doPeopleRequest(ctx, payload, groupsUnionListPropertyUrl)
+	return a.doPeopleRequest(ctx, EndpointGroups, payload, groupsUnionListPropertyUrl)
 }
 
 type groupDeletePayload struct {
@@ -556,6 +835,7 @@ type groupDeletePayload struct {
 	GroupKey string `json:"$group_key"`
 	GroupId  string `json:"$group_id"`
 	Delete   string `json:"$delete"`
+	profileModifiers
 }
 
 // GroupDelete calls the Groups Delete API
@@ -563,12 +843,13 @@ type groupDeletePayload struct {
 func (a *ApiClient) GroupDelete(ctx context.Context, groupKey, groupID string) error {
 	payload := []groupDeletePayload{
 		{
-			Token:    a.token,
-			GroupKey: groupKey,
-			GroupId:  groupID,
-			Delete:   "null",
+			Token:            a.token,
+			GroupKey:         groupKey,
+			GroupId:          a.redactID(groupID),
+			Delete:           "null",
+			profileModifiers: a.peopleUpdateOptions.modifiers(a.redaction),
 		},
 	}
 
-	return a.doPeopleRequest(ctx, payload, groupsDeleteGroupUrl)
+	return a.doPeopleRequest(ctx, EndpointGroups, payload, groupsDeleteGroupUrl)
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
 	"net/url"
@@ -238,6 +239,87 @@ func TestTrack(t *testing.T) {
 	})
 }
 
+func TestTrackWithOptions(t *testing.T) {
+	setupTrackEndpoint := func(t *testing.T, client *ApiClient, query url.Values, testRequest func(*http.Request, []*Event), status int, body string) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponderWithQuery(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, trackURL), query, func(req *http.Request) (*http.Response, error) {
+			testRequest(req, decodeImportRequest(t, req))
+			return httpmock.NewStringResponse(status, body), nil
+		})
+	}
+
+	t.Run("gzip, ip and strict", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		events := []*Event{mp.NewEvent("sample_event", EmptyDistinctID, map[string]any{})}
+
+		query := url.Values{"verbose": {"1"}, "ip": {"1"}, "strict": {"1"}}
+		setupTrackEndpoint(t, mp, query, func(req *http.Request, r []*Event) {
+			require.Equal(t, "gzip", req.Header.Get("content-encoding"))
+			require.ElementsMatch(t, events, r)
+		}, http.StatusOK, `{"code": 200, "num_records_imported": 1, "status": 1}`)
+
+		result, err := mp.TrackWithOptions(ctx, events, TrackOptions{Compression: Gzip, IP: true, Strict: true})
+		require.NoError(t, err)
+		require.Equal(t, &TrackResult{Code: http.StatusOK, NumRecordsImported: 1, Status: float64(1)}, result)
+	})
+
+	t.Run("strict validation failures", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		events := []*Event{
+			mp.NewEvent("sample_event", EmptyDistinctID, map[string]any{}),
+			mp.NewEvent("sample_event", EmptyDistinctID, map[string]any{}),
+		}
+
+		setupTrackEndpoint(t, mp, url.Values{"verbose": {"1"}, "strict": {"1"}}, func(req *http.Request, r []*Event) {}, http.StatusBadRequest, `{
+			"code": 400,
+			"error": "some data points in the request failed validation",
+			"num_records_imported": 1,
+			"status": "Bad Request",
+			"failed_records": [{"index": 1, "insert_id": "", "field": "properties.time", "message": "'properties.time' is invalid"}]
+		}`)
+
+		_, err := mp.TrackWithOptions(ctx, events, TrackOptions{Strict: true})
+		validationError := ImportFailedValidationError{}
+		require.ErrorAs(t, err, &validationError)
+		require.Equal(t, 1, validationError.NumRecordsImported)
+		require.Equal(t, 1, validationError.FailedImportRecords[0].Index)
+	})
+
+	t.Run("verbose error", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		events := []*Event{mp.NewEvent("sample_event", EmptyDistinctID, map[string]any{})}
+
+		setupTrackEndpoint(t, mp, url.Values{"verbose": {"1"}}, func(req *http.Request, r []*Event) {
+			require.Empty(t, req.Header.Get("content-encoding"))
+			require.False(t, req.URL.Query().Has("ip"))
+			require.False(t, req.URL.Query().Has("strict"))
+		}, http.StatusOK, `{"error": "some error occurred", "status": 0}`)
+
+		_, err := mp.TrackWithOptions(ctx, events, TrackOptions{})
+		verboseError := VerboseError{}
+		require.ErrorAs(t, err, &verboseError)
+		require.Equal(t, "some error occurred", verboseError.ApiError)
+	})
+
+	t.Run("server errors", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		events := []*Event{mp.NewEvent("sample_event", EmptyDistinctID, map[string]any{})}
+
+		setupTrackEndpoint(t, mp, url.Values{"verbose": {"1"}}, func(req *http.Request, r []*Event) {}, http.StatusServiceUnavailable, `unavailable`)
+
+		_, err := mp.TrackWithOptions(ctx, events, TrackOptions{Compression: Gzip})
+		httpError := HttpError{}
+		require.ErrorAs(t, err, &httpError)
+		require.Equal(t, http.StatusServiceUnavailable, httpError.Status)
+	})
+}
+
 func TestImport(t *testing.T) {
 	setupHttpEndpointTest := func(t *testing.T, client *ApiClient, queryValues url.Values, testPayload func([]*Event), httpResponse *http.Response) {
 		httpmock.Activate()
@@ -519,6 +601,21 @@ func TestImport(t *testing.T) {
 		}
 	})
 
+	t.Run("status code error that is not json", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
+		setupHttpEndpointTest(t, mp, getValues(117, ImportOptionsRecommend.Strict), func(r []*Event) {}, &http.Response{
+			StatusCode: http.StatusRequestEntityTooLarge,
+			Body:       io.NopCloser(strings.NewReader("<html><body>413 Request Entity Too Large</body></html>")),
+		})
+
+		_, err := mp.Import(ctx, []*Event{mp.NewEvent("import-event", EmptyDistinctID, map[string]any{})}, ImportOptionsRecommend)
+		genericError := &ImportGenericError{}
+		require.ErrorAs(t, err, genericError)
+		require.Equal(t, http.StatusRequestEntityTooLarge, genericError.Code)
+		require.Equal(t, "<html><body>413 Request Entity Too Large</body></html>", genericError.ApiError)
+	})
+
 	t.Run("unknown status code", func(t *testing.T) {
 		ctx := context.Background()
 		mp := NewApiClient("token", ServiceAccount(117, "user-name", "secret"))
@@ -891,7 +988,7 @@ func TestPeoplePeopleIncrement(t *testing.T) {
 		payload := arrayPayload[0]
 		require.Equal(t, mp.token, payload.Token)
 		require.Equal(t, "some-id", payload.DistinctID)
-		require.Equal(t, 1, payload.Add["some-prop"])
+		require.Equal(t, json.Number("1"), payload.Add["some-prop"])
 
 	}, peopleAndGroupSuccess())
 
@@ -900,6 +997,42 @@ func TestPeoplePeopleIncrement(t *testing.T) {
 	}))
 }
 
+func TestPeopleAdd(t *testing.T) {
+	t.Run("mixed numeric values keep their precision", func(t *testing.T) {
+		ctx := context.Background()
+
+		mp := NewApiClient("token")
+		setupPeopleAndGroupsEndpoint(t, mp, peopleIncrementUrl, func(body io.Reader) {
+			data, err := io.ReadAll(body)
+			require.NoError(t, err)
+			require.Contains(t, string(data), `"revenue":12.5`)
+			require.Contains(t, string(data), `"balance":-0.1`)
+			require.Contains(t, string(data), `"big":9007199254740993`)
+			require.Contains(t, string(data), `"exact":19.99000000000000000001`)
+			require.Contains(t, string(data), `"score":1.5`)
+			require.Contains(t, string(data), `"count":-3`)
+		}, peopleAndGroupSuccess())
+
+		require.NoError(t, mp.PeopleAdd(ctx, "some-id", map[string]any{
+			"revenue": 12.50,
+			"balance": -0.1,
+			"big":     int64(9007199254740993),
+			"exact":   "19.99000000000000000001",
+			"score":   json.Number("1.5"),
+			"count":   int8(-3),
+		}))
+	})
+
+	t.Run("invalid values are rejected", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+
+		for _, value := range []any{"12,50", "abc", "", math.NaN(), math.Inf(1), true, nil} {
+			require.Error(t, mp.PeopleAdd(ctx, "some-id", map[string]any{"revenue": value}), value)
+		}
+	})
+}
+
 func TestPeopleAppendListProperty(t *testing.T) {
 	ctx := context.Background()
 
@@ -0,0 +1,110 @@
+package mixpanel
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// insertIDLength fits the 36 characters mixpanel allows for $insert_id
+const insertIDLength = 32
+
+// InsertIDMode is how $insert_id is generated for events that don't have one
+type InsertIDMode int
+
+const (
+	// InsertIDRandom generates a random id
+	InsertIDRandom InsertIDMode = iota
+	// InsertIDDeterministic hashes the event name, distinct_id, time and the configured properties
+	// so sending the same event twice produces the same id
+	// Events without time get a random id
+	InsertIDDeterministic
+)
+
+type insertIDGenerator struct {
+	mode       InsertIDMode
+	properties []string
+}
+
+// WithInsertIDs adds an $insert_id to the events passed to Track and Import that don't have one
+// hashProperties are the extra properties hashed by InsertIDDeterministic
+func WithInsertIDs(mode InsertIDMode, hashProperties ...string) Options {
+	return func(mixpanel *ApiClient) {
+		properties := append([]string(nil), hashProperties...)
+		sort.Strings(properties)
+
+		mixpanel.insertIDs = &insertIDGenerator{
+			mode:       mode,
+			properties: properties,
+		}
+	}
+}
+
+func newRandomInsertID() (string, error) {
+	id := make([]byte, insertIDLength/2)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("failed to generate insert id: %w", err)
+	}
+	return hex.EncodeToString(id), nil
+}
+
+// newDeterministicInsertID hashes the identifying fields of the event
+func newDeterministicInsertID(event *Event, properties []string) (string, error) {
+	hash := sha256.New()
+	write := func(value any) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("failed to hash insert id: %w", err)
+		}
+		hash.Write(data)
+		hash.Write([]byte{0})
+		return nil
+	}
+
+	if err := write(event.Name); err != nil {
+		return "", err
+	}
+	for _, key := range append([]string{propertyDistinctID, propertyTime}, properties...) {
+		if err := write(key); err != nil {
+			return "", err
+		}
+		if err := write(event.Properties[key]); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(hash.Sum(nil))[:insertIDLength], nil
+}
+
+func (g *insertIDGenerator) generate(event *Event) (string, error) {
+	if _, hasTime := event.Properties[propertyTime]; g.mode == InsertIDDeterministic && hasTime {
+		return newDeterministicInsertID(event, g.properties)
+	}
+	return newRandomInsertID()
+}
+
+// addInsertIDs adds an $insert_id to the events that are missing one
+func (m *ApiClient) addInsertIDs(events []*Event) error {
+	if m.insertIDs == nil {
+		return nil
+	}
+
+	for _, event := range events {
+		if event == nil || event.Properties == nil {
+			continue
+		}
+		if _, ok := event.Properties[propertyInsertID]; ok {
+			continue
+		}
+
+		insertID, err := m.insertIDs.generate(event)
+		if err != nil {
+			return err
+		}
+		event.AddInsertID(insertID)
+	}
+	return nil
+}
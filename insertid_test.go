@@ -0,0 +1,84 @@
+package mixpanel
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInsertIDs(t *testing.T) {
+	eventTime := time.Date(2023, 5, 24, 12, 0, 0, 0, time.UTC)
+	newTimedEvent := func(mp *ApiClient, properties map[string]any) *Event {
+		event := mp.NewEvent("purchase", "user-1", properties)
+		event.AddTime(eventTime)
+		return event
+	}
+
+	t.Run("random ids are valid and unique", func(t *testing.T) {
+		mp := NewApiClient("token", WithInsertIDs(InsertIDRandom))
+		events := []*Event{newTimedEvent(mp, nil), newTimedEvent(mp, nil)}
+		require.NoError(t, mp.addInsertIDs(events))
+
+		require.Empty(t, ValidateEvents(events))
+		require.NotEqual(t, events[0].Properties[propertyInsertID], events[1].Properties[propertyInsertID])
+	})
+
+	t.Run("deterministic ids are stable", func(t *testing.T) {
+		mp := NewApiClient("token", WithInsertIDs(InsertIDDeterministic, "order_id"))
+		events := []*Event{
+			newTimedEvent(mp, map[string]any{"order_id": 1, "ignored": "a"}),
+			newTimedEvent(mp, map[string]any{"order_id": 1, "ignored": "b"}),
+			newTimedEvent(mp, map[string]any{"order_id": 2}),
+		}
+		require.NoError(t, mp.addInsertIDs(events))
+
+		require.Empty(t, ValidateEvents(events))
+		require.Len(t, events[0].Properties[propertyInsertID], insertIDLength)
+		require.Equal(t, events[0].Properties[propertyInsertID], events[1].Properties[propertyInsertID])
+		require.NotEqual(t, events[0].Properties[propertyInsertID], events[2].Properties[propertyInsertID])
+	})
+
+	t.Run("deterministic ids match after a json round trip", func(t *testing.T) {
+		mp := NewApiClient("token", WithInsertIDs(InsertIDDeterministic))
+		event := newTimedEvent(mp, nil)
+		fromJson, err := mp.NewEventFromJson(map[string]any{
+			"event": "purchase",
+			"properties": map[string]any{
+				propertyDistinctID: "user-1",
+				propertyTime:       float64(eventTime.UnixMilli()),
+			},
+		})
+		require.NoError(t, err)
+
+		require.NoError(t, mp.addInsertIDs([]*Event{event, fromJson}))
+		require.Equal(t, event.Properties[propertyInsertID], fromJson.Properties[propertyInsertID])
+	})
+
+	t.Run("existing ids are kept", func(t *testing.T) {
+		mp := NewApiClient("token", WithInsertIDs(InsertIDRandom))
+		event := newTimedEvent(mp, nil)
+		event.AddInsertID("my-id")
+		require.NoError(t, mp.addInsertIDs([]*Event{event}))
+		require.Equal(t, "my-id", event.Properties[propertyInsertID])
+	})
+
+	t.Run("ids are added at send time", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithInsertIDs(InsertIDDeterministic))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, mp.apiEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			events := decodeImportRequest(t, req)
+			require.Len(t, events[0].Properties[propertyInsertID], insertIDLength)
+			return httpmock.NewStringResponse(http.StatusOK, `{"code": 200,"num_records_imported": 1,"status": 1}`), nil
+		})
+
+		_, err := mp.Import(ctx, []*Event{newTimedEvent(mp, nil)}, ImportOptionsRecommend)
+		require.NoError(t, err)
+	})
+}
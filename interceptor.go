@@ -0,0 +1,111 @@
+package mixpanel
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// EventInterceptor transforms an event before it is sent by Track or Import
+// Return the event to send, which can be a different one, nil to drop the event or an error to drop and report it
+type EventInterceptor func(ctx context.Context, event *Event) (*Event, error)
+
+// InterceptorStats counts the events the interceptors did not let through
+type InterceptorStats struct {
+	// Dropped is the number of events an interceptor returned nil for
+	Dropped int64
+	// Errored is the number of events an interceptor returned an error for
+	Errored int64
+}
+
+type interceptorChain struct {
+	interceptors []EventInterceptor
+	onDrop       func(event *Event, err error)
+
+	dropped atomic.Int64
+	errored atomic.Int64
+}
+
+// WithEventInterceptors adds interceptors that run in order on every event passed to Track and Import,
+// including the feature flags exposure events
+// Each interceptor receives the event returned by the previous one
+func WithEventInterceptors(interceptors ...EventInterceptor) Options {
+	return func(mixpanel *ApiClient) {
+		if mixpanel.interceptors == nil {
+			mixpanel.interceptors = &interceptorChain{}
+		}
+		mixpanel.interceptors.interceptors = append(mixpanel.interceptors.interceptors, interceptors...)
+	}
+}
+
+// WithInterceptorDropHandler is called with every event dropped by an interceptor
+// err is nil when the interceptor dropped the event by returning nil
+func WithInterceptorDropHandler(handler func(event *Event, err error)) Options {
+	return func(mixpanel *ApiClient) {
+		if mixpanel.interceptors == nil {
+			mixpanel.interceptors = &interceptorChain{}
+		}
+		mixpanel.interceptors.onDrop = handler
+	}
+}
+
+// InterceptorStats returns how many events the interceptors dropped since the client was created
+func (m *ApiClient) InterceptorStats() InterceptorStats {
+	if m.interceptors == nil {
+		return InterceptorStats{}
+	}
+	return InterceptorStats{
+		Dropped: m.interceptors.dropped.Load(),
+		Errored: m.interceptors.errored.Load(),
+	}
+}
+
+// intercept runs the interceptors on the events
+// Returns the events to send and the index in events of every event returned
+func (m *ApiClient) intercept(ctx context.Context, events []*Event) ([]*Event, []int) {
+	if m.interceptors == nil || len(m.interceptors.interceptors) == 0 {
+		return events, nil
+	}
+
+	send := make([]*Event, 0, len(events))
+	indexes := make([]int, 0, len(events))
+	for i, event := range events {
+		intercepted, err := m.interceptors.run(ctx, event)
+		if intercepted == nil || err != nil {
+			continue
+		}
+		send = append(send, intercepted)
+		indexes = append(indexes, i)
+	}
+	return send, indexes
+}
+
+func (c *interceptorChain) run(ctx context.Context, event *Event) (*Event, error) {
+	original := event
+	for _, interceptor := range c.interceptors {
+		intercepted, err := interceptor(ctx, event)
+		if err != nil {
+			c.errored.Add(1)
+			if c.onDrop != nil {
+				c.onDrop(original, err)
+			}
+			return nil, err
+		}
+		if intercepted == nil {
+			c.dropped.Add(1)
+			if c.onDrop != nil {
+				c.onDrop(original, nil)
+			}
+			return nil, nil
+		}
+		event = intercepted
+	}
+	return event, nil
+}
+
+// originalIndex maps the index in the intercepted events back to the index in the events passed by the caller
+func originalIndex(indexes []int, index int) int {
+	if indexes == nil || index < 0 || index >= len(indexes) {
+		return index
+	}
+	return indexes[index]
+}
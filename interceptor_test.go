@@ -0,0 +1,176 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/mixpanel/mixpanel-go/v2/flags"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventInterceptors(t *testing.T) {
+	errInterceptor := errors.New("interceptor failed")
+
+	enrich := func(ctx context.Context, event *Event) (*Event, error) {
+		event.Properties["enriched"] = true
+		return event, nil
+	}
+	dropOdd := func(ctx context.Context, event *Event) (*Event, error) {
+		var i int
+		_, _ = fmt.Sscanf(event.Name, "event_%d", &i)
+		if i%2 == 1 {
+			return nil, nil
+		}
+		return event, nil
+	}
+	failFour := func(ctx context.Context, event *Event) (*Event, error) {
+		if event.Name == "event_4" {
+			return nil, errInterceptor
+		}
+		return event, nil
+	}
+
+	setupTrackEndpoint := func(t *testing.T, client *ApiClient) *[]*Event {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var tracked []*Event
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			var events []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&events))
+			tracked = append(tracked, events...)
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+		return &tracked
+	}
+
+	t.Run("run in order and drop events on track", func(t *testing.T) {
+		ctx := context.Background()
+		type dropped struct {
+			name string
+			err  error
+		}
+		var drops []dropped
+		mp := NewApiClient("token",
+			WithEventInterceptors(dropOdd, failFour),
+			WithEventInterceptors(enrich),
+			WithInterceptorDropHandler(func(event *Event, err error) {
+				drops = append(drops, dropped{event.Name, err})
+			}),
+		)
+		tracked := setupTrackEndpoint(t, mp)
+
+		require.NoError(t, mp.Track(ctx, makeEvents(mp, 6)))
+		require.Len(t, *tracked, 2)
+		require.Equal(t, "event_0", (*tracked)[0].Name)
+		require.Equal(t, "event_2", (*tracked)[1].Name)
+		require.Equal(t, true, (*tracked)[0].Properties["enriched"])
+
+		require.Equal(t, []dropped{
+			{"event_1", nil},
+			{"event_3", nil},
+			{"event_4", errInterceptor},
+			{"event_5", nil},
+		}, drops)
+		require.Equal(t, InterceptorStats{Dropped: 3, Errored: 1}, mp.InterceptorStats())
+	})
+
+	t.Run("interceptors can replace events", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithEventInterceptors(func(ctx context.Context, event *Event) (*Event, error) {
+			return &Event{Name: "renamed", Properties: event.Properties}, nil
+		}))
+		tracked := setupTrackEndpoint(t, mp)
+
+		require.NoError(t, mp.Track(ctx, makeEvents(mp, 1)))
+		require.Equal(t, "renamed", (*tracked)[0].Name)
+	})
+
+	t.Run("track does not call the api when every event is dropped", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithEventInterceptors(func(ctx context.Context, event *Event) (*Event, error) {
+			return nil, nil
+		}))
+		setupTrackEndpoint(t, mp)
+
+		require.NoError(t, mp.Track(ctx, makeEvents(mp, 3)))
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("import maps failed records to the original index", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithEventInterceptors(dropOdd))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, importURL), func(req *http.Request) (*http.Response, error) {
+			events := decodeImportRequest(t, req)
+			require.Len(t, events, 3)
+			return httpmock.NewStringResponse(http.StatusBadRequest, `{
+				"code": 400,
+				"error": "some data points in the request failed validation",
+				"num_records_imported": 2,
+				"failed_records": [{"index": 1, "field": "properties.time", "message": "invalid"}]
+			}`), nil
+		})
+
+		_, err := mp.Import(ctx, makeEvents(mp, 6), ImportOptionsRecommend)
+		validationError := ImportFailedValidationError{}
+		require.ErrorAs(t, err, &validationError)
+		require.Equal(t, 2, validationError.FailedImportRecords[0].Index)
+	})
+
+	t.Run("import all runs the interceptors once", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithEventInterceptors(dropOdd))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, importURL), func(req *http.Request) (*http.Response, error) {
+			events := decodeImportRequest(t, req)
+			if len(events) > 1 {
+				return httpmock.NewStringResponse(http.StatusRequestEntityTooLarge, `{"code": 413, "error": "request too large", "status": 0}`), nil
+			}
+			if events[0].Name == "event_2" {
+				return httpmock.NewStringResponse(http.StatusBadRequest, `{"code": 400, "error": "invalid", "failed_records": [{"index": 0, "field": "event", "message": "invalid"}]}`), nil
+			}
+			return httpmock.NewStringResponse(http.StatusOK, `{"code": 200,"num_records_imported": 1,"status": 1}`), nil
+		})
+
+		result, err := mp.ImportAll(ctx, makeEvents(mp, 4), ImportOptionsRecommend)
+		batchError := BatchError{}
+		require.ErrorAs(t, err, &batchError)
+		require.Equal(t, 0, batchError.Chunks[0].Offset)
+		require.Equal(t, 1, result.NumRecordsImported)
+		require.Len(t, result.FailedImportRecords, 1)
+		require.Equal(t, 2, result.FailedImportRecords[0].Index)
+		require.Equal(t, InterceptorStats{Dropped: 2}, mp.InterceptorStats())
+	})
+
+	t.Run("run on feature flag exposure events", func(t *testing.T) {
+		mp := NewApiClient("token",
+			WithEventInterceptors(enrich),
+			WithRemoteFlags(flags.DefaultRemoteFlagsConfig()),
+		)
+		tracked := setupTrackEndpoint(t, mp)
+
+		variant := "on"
+		mp.RemoteFlags.TrackExposureEvent(context.Background(), "flag", flags.SelectedVariant{VariantKey: &variant}, flags.FlagContext{"distinct_id": "user-1"})
+		require.Len(t, *tracked, 1)
+		require.Equal(t, true, (*tracked)[0].Properties["enriched"])
+	})
+
+	t.Run("no interceptors", func(t *testing.T) {
+		mp := NewApiClient("token")
+		events := makeEvents(mp, 2)
+		intercepted, indexes := mp.intercept(context.Background(), events)
+		require.Equal(t, events, intercepted)
+		require.Nil(t, indexes)
+		require.Equal(t, InterceptorStats{}, mp.InterceptorStats())
+	})
+}
@@ -20,6 +20,9 @@ const (
 	euEndpoint     = "https://api-eu.mixpanel.com"
 	euDataEndpoint = "https://data-eu.mixpanel.com"
 
+	usQueryEndpoint = "https://mixpanel.com"
+	euQueryEndpoint = "https://eu.mixpanel.com"
+
 	EmptyDistinctID = ""
 
 	propertyToken      = "token"
@@ -32,13 +35,14 @@ const (
 	goLib              = "go"
 	propertyLibVersion = "$lib_version"
 
-	acceptHeader               = "Accept"
-	acceptPlainTextHeader      = "text/plain"
-	acceptJsonHeader           = "application/json"
-	contentEncodingHeader      = "Content-Encoding"
-	contentTypeHeader          = "Content-Type"
-	contentTypeApplicationJson = "application/json"
-	contentTypeApplicationForm = "application/x-www-form-urlencoded"
+	acceptHeader                 = "Accept"
+	acceptPlainTextHeader        = "text/plain"
+	acceptJsonHeader             = "application/json"
+	contentEncodingHeader        = "Content-Encoding"
+	contentTypeHeader            = "Content-Type"
+	contentTypeApplicationJson   = "application/json"
+	contentTypeApplicationForm   = "application/x-www-form-urlencoded"
+	contentTypeApplicationNdjson = "application/x-ndjson"
 )
 
 type Ingestion interface {
@@ -94,9 +98,10 @@ type serviceAccount struct {
 }
 
 type ApiClient struct {
-	client       *http.Client
-	apiEndpoint  string
-	dataEndpoint string
+	client        *http.Client
+	apiEndpoint   string
+	dataEndpoint  string
+	queryEndpoint string
 
 	projectID int
 	token     string
@@ -104,6 +109,18 @@ type ApiClient struct {
 
 	serviceAccount *serviceAccount
 	debugHttpCall  *debugHttpCalls
+	retryPolicy    *RetryPolicy
+	validation     *ValidationConfig
+	insertIDs      *insertIDGenerator
+	interceptors   *interceptorChain
+	redaction      *redactor
+
+	superProperties     superProperties
+	peopleUpdateOptions PeopleUpdateOptions
+	identityMode        IdentityMode
+
+	queue *eventQueue
+	spool *spool
 
 	// Feature flags providers
 	LocalFlags  *flags.LocalFeatureFlagsProvider
@@ -131,6 +148,7 @@ func EuResidency() Options {
 	return func(mixpanel *ApiClient) {
 		mixpanel.apiEndpoint = euEndpoint
 		mixpanel.dataEndpoint = euDataEndpoint
+		mixpanel.queryEndpoint = euQueryEndpoint
 	}
 }
 
@@ -150,6 +168,14 @@ func ProxyDataLocation(proxy string) Options {
 	}
 }
 
+// ProxyQueryLocation sets the mixpanel client to use the custom location for all query requests
+// Example: http://locahosthost:8080
+func ProxyQueryLocation(proxy string) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.queryEndpoint = proxy
+	}
+}
+
 // ServiceAccount add a service account to the mixpanel client
 // https://developer.mixpanel.com/reference/service-accounts-api
 func ServiceAccount(projectID int, username, secret string) Options {
@@ -199,6 +225,7 @@ func NewApiClient(token string, options ...Options) *ApiClient {
 		client:        http.DefaultClient,
 		apiEndpoint:   usEndpoint,
 		dataEndpoint:  usDataEndpoint,
+		queryEndpoint: usQueryEndpoint,
 		token:         token,
 		debugHttpCall: &debugHttpCalls{},
 	}
@@ -207,6 +234,13 @@ func NewApiClient(token string, options ...Options) *ApiClient {
 		o(mp)
 	}
 
+	if mp.queue != nil {
+		mp.queue.start()
+	}
+	if mp.spool != nil {
+		mp.spool.start()
+	}
+
 	return mp
 }
 
@@ -217,6 +251,7 @@ type Event struct {
 }
 
 // NewEvent creates a new mixpanel event to track
+// Super properties are added unless the same property is set in properties
 func (m *ApiClient) NewEvent(name string, distinctID string, properties map[string]any) *Event {
 	e := &Event{
 		Name: name,
@@ -224,6 +259,7 @@ func (m *ApiClient) NewEvent(name string, distinctID string, properties map[stri
 	if properties == nil {
 		properties = make(map[string]any)
 	}
+	m.superProperties.merge(properties)
 
 	properties[propertyToken] = m.token
 	properties[propertyDistinctID] = distinctID
@@ -234,6 +270,24 @@ func (m *ApiClient) NewEvent(name string, distinctID string, properties map[stri
 	return e
 }
 
+// NewIdentifiedEvent creates a new mixpanel event for Simplified ID Merge
+// The event gets the $device_id and the $user_id that are not empty, its distinct_id is the user id or "$device:" and the device id for anonymous users
+func (m *ApiClient) NewIdentifiedEvent(name, deviceID, userID string, properties map[string]any) *Event {
+	distinctID := userID
+	if distinctID == "" && deviceID != "" {
+		distinctID = deviceIDPrefix + deviceID
+	}
+
+	e := m.NewEvent(name, distinctID, properties)
+	if deviceID != "" {
+		e.AddDeviceID(deviceID)
+	}
+	if userID != "" {
+		e.AddUserID(userID)
+	}
+	return e
+}
+
 func (m *ApiClient) NewEventFromJson(json map[string]any) (*Event, error) {
 	name, ok := json["event"].(string)
 	if !ok {
@@ -263,6 +317,18 @@ func (e *Event) AddInsertID(insertID string) {
 	e.Properties[propertyInsertID] = insertID
 }
 
+// AddDeviceID inserts the $device_id property used by Simplified ID Merge for the anonymous id of the user
+// https://docs.mixpanel.com/docs/tracking-methods/id-management/identifying-users-simplified
+func (e *Event) AddDeviceID(deviceID string) {
+	e.Properties[propertyDeviceID] = deviceID
+}
+
+// AddUserID inserts the $user_id property used by Simplified ID Merge for the id of the logged in user
+// https://docs.mixpanel.com/docs/tracking-methods/id-management/identifying-users-simplified
+func (e *Event) AddUserID(userID string) {
+	e.Properties[propertyUserID] = userID
+}
+
 // AddIP if you supply a property ip with an IP address
 // Mixpanel will automatically do a GeoIP lookup and replace the ip property with geographic properties (City, Country, Region). These properties can be used in our UI to segment events geographically.
 // https://developer.mixpanel.com/reference/import-events#geoip-enrichment
@@ -12,6 +12,7 @@ func TestMixpanelOptions(t *testing.T) {
 		mp := NewApiClient("", EuResidency())
 		require.Equal(t, mp.apiEndpoint, euEndpoint)
 		require.Equal(t, mp.dataEndpoint, euDataEndpoint)
+		require.Equal(t, mp.queryEndpoint, euQueryEndpoint)
 	})
 
 	t.Run("api secret", func(t *testing.T) {
@@ -37,11 +38,31 @@ func TestMixpanelOptions(t *testing.T) {
 		require.Equal(t, "https://localhost:8080", mp.dataEndpoint)
 	})
 
+	t.Run("set query proxy", func(t *testing.T) {
+		mp := NewApiClient("", ProxyQueryLocation("https://localhost:8080"))
+		require.Equal(t, "https://localhost:8080", mp.queryEndpoint)
+	})
+
 	t.Run("debug http", func(t *testing.T) {
 		mp := NewApiClient("", DebugHttpCalls(os.Stdout))
 		require.NotNil(t, mp.debugHttpCall)
 	})
 
+	t.Run("retry policy", func(t *testing.T) {
+		mp := NewApiClient("", WithRetryPolicy(DefaultRetryPolicy()))
+		require.NotNil(t, mp.retryPolicy)
+		require.True(t, mp.retryPolicy.appliesTo(EndpointImport))
+		require.False(t, mp.retryPolicy.appliesTo(EndpointPeopleIncrement))
+	})
+
+	t.Run("identity mode", func(t *testing.T) {
+		mp := NewApiClient("")
+		require.Equal(t, IdentityModeOriginal, mp.identityMode)
+
+		mp = NewApiClient("", WithIdentityMode(IdentityModeSimplified))
+		require.Equal(t, IdentityModeSimplified, mp.identityMode)
+	})
+
 	t.Run("http client", func(t *testing.T) {
 		mp := NewApiClient("", HttpClient(nil))
 		require.Nil(t, mp.client)
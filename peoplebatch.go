@@ -0,0 +1,312 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+const (
+	peopleBatchURL = "/engage#profile-batch-update"
+
+	profileOperationSet     = "$set"
+	profileOperationSetOnce = "$set_once"
+	profileOperationAdd     = "$add"
+	profileOperationUnion   = "$union"
+	profileOperationAppend  = "$append"
+	profileOperationRemove  = "$remove"
+	profileOperationUnset   = "$unset"
+	profileOperationDelete  = "$delete"
+)
+
+// PeopleRecordError is the error of a single update of a PeopleBatch
+type PeopleRecordError struct {
+	// Index is the position of the update in the batch, in the order the updates were added
+	Index      int
+	DistinctID string
+	// Operation is the people operation of the update, like $set or $add
+	Operation string
+	Err       error
+}
+
+func (e PeopleRecordError) Error() string {
+	return fmt.Sprintf("%s for %q failed: %s", e.Operation, e.DistinctID, e.Err)
+}
+
+func (e PeopleRecordError) Unwrap() error {
+	return e.Err
+}
+
+// PeopleBatchError is returned by PeopleBatch.Flush when one or more updates failed
+type PeopleBatchError struct {
+	// Records are sorted by Index
+	Records []PeopleRecordError
+}
+
+func (e PeopleBatchError) Error() string {
+	return fmt.Sprintf("%d people updates failed, first error: %s", len(e.Records), e.Records[0].Error())
+}
+
+// Unwrap returns the first record error
+func (e PeopleBatchError) Unwrap() error {
+	return e.Records[0]
+}
+
+type profileUpdate struct {
+	// id is the distinct_id or the group id
+	id string
+	// key is the group key, empty for people
+	key       string
+	operation string
+	payload   any
+	// err is set for updates that cannot be sent
+	err error
+}
+
+// profileUpdates are the updates of a batch, safe to add from multiple goroutines
+type profileUpdates struct {
+	mu            sync.Mutex
+	updates       []profileUpdate
+	updateOptions PeopleUpdateOptions
+}
+
+func (u *profileUpdates) options() PeopleUpdateOptions {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.updateOptions
+}
+
+func (u *profileUpdates) setOptions(options PeopleUpdateOptions) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.updateOptions = options
+}
+
+func (u *profileUpdates) add(update profileUpdate) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.updates = append(u.updates, update)
+}
+
+func (u *profileUpdates) take() []profileUpdate {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	updates := u.updates
+	u.updates = nil
+	return updates
+}
+
+// Len returns the number of updates waiting for Flush
+func (u *profileUpdates) Len() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.updates)
+}
+
+// PeopleBatch collects people updates for many users and sends them together
+// The updates are sent in the order they were added, in requests of at most MaxPeopleEvents
+type PeopleBatch struct {
+	profileUpdates
+	client *ApiClient
+}
+
+// NewPeopleBatch returns an empty batch, it is safe to add updates from multiple goroutines
+// The updates use the PeopleUpdateOptions of the client until WithOptions is called
+func (a *ApiClient) NewPeopleBatch() *PeopleBatch {
+	b := &PeopleBatch{client: a}
+	b.setOptions(a.peopleUpdateOptions)
+	return b
+}
+
+// WithOptions sets the modifiers of the updates added after the call
+func (b *PeopleBatch) WithOptions(options PeopleUpdateOptions) *PeopleBatch {
+	b.setOptions(options)
+	return b
+}
+
+func (b *PeopleBatch) add(distinctID, operation string, payload any) *PeopleBatch {
+	var err error
+	if distinctID == "" {
+		err = errors.New("distinct_id is empty")
+	}
+	return b.addUpdate(distinctID, operation, payload, err)
+}
+
+// addUpdate adds the update, an update with an error is reported as failed by Flush
+func (b *PeopleBatch) addUpdate(distinctID, operation string, payload any, err error) *PeopleBatch {
+	b.profileUpdates.add(profileUpdate{
+		id:        distinctID,
+		operation: operation,
+		payload:   payload,
+		err:       err,
+	})
+	return b
+}
+
+// Set adds a $set update, see PeopleSet
+func (b *PeopleBatch) Set(people *PeopleProperties) *PeopleBatch {
+	p := b.client.redactPeople(people)
+	return b.add(people.DistinctID, profileOperationSet, peopleSetPayload{
+		Token:            b.client.token,
+		DistinctID:       p.DistinctID,
+		Set:              p.Properties,
+		profileModifiers: b.options().peopleModifiers(people, b.client.redaction),
+	})
+}
+
+// SetOnce adds a $set_once update, see PeopleSetOnce
+func (b *PeopleBatch) SetOnce(people *PeopleProperties) *PeopleBatch {
+	p := b.client.redactPeople(people)
+	return b.add(people.DistinctID, profileOperationSetOnce, peopleSetOncePayload{
+		Token:            b.client.token,
+		DistinctID:       p.DistinctID,
+		SetOnce:          p.Properties,
+		profileModifiers: b.options().peopleModifiers(people, b.client.redaction),
+	})
+}
+
+// Increment adds an $add update, see PeopleIncrement
+func (b *PeopleBatch) Increment(distinctID string, add map[string]int) *PeopleBatch {
+	return b.Add(distinctID, intIncrements(add))
+}
+
+// Add adds an $add update with any numeric values, see PeopleAdd
+// An invalid value is reported by Flush
+func (b *PeopleBatch) Add(distinctID string, add map[string]any) *PeopleBatch {
+	numbers, err := numericIncrements(b.client.redactIncrements(add))
+	if err != nil {
+		return b.addUpdate(distinctID, profileOperationAdd, nil, err)
+	}
+	return b.add(distinctID, profileOperationAdd, peopleNumericalAddPayload{
+		Token:            b.client.token,
+		DistinctID:       b.client.redactID(distinctID),
+		Add:              numbers,
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Union adds a $union update, see PeopleUnionProperty
+func (b *PeopleBatch) Union(distinctID string, union map[string]any) *PeopleBatch {
+	return b.add(distinctID, profileOperationUnion, peopleUnionPayload{
+		Token:            b.client.token,
+		DistinctID:       b.client.redactID(distinctID),
+		Union:            b.client.redactProperties(union),
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Append adds an $append update, see PeopleAppendListProperty
+func (b *PeopleBatch) Append(distinctID string, append map[string]any) *PeopleBatch {
+	return b.add(distinctID, profileOperationAppend, peopleAppendListPayload{
+		Token:            b.client.token,
+		DistinctID:       b.client.redactID(distinctID),
+		Append:           b.client.redactProperties(append),
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Remove adds a $remove update, see PeopleRemoveListProperty
+func (b *PeopleBatch) Remove(distinctID string, remove map[string]any) *PeopleBatch {
+	return b.add(distinctID, profileOperationRemove, peopleListRemovePayload{
+		Token:            b.client.token,
+		DistinctID:       b.client.redactID(distinctID),
+		Remove:           b.client.redactProperties(remove),
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Unset adds an $unset update, see PeopleDeleteProperty
+func (b *PeopleBatch) Unset(distinctID string, unset []string) *PeopleBatch {
+	return b.add(distinctID, profileOperationUnset, peopleDeletePropertyPayload{
+		Token:            b.client.token,
+		DistinctID:       b.client.redactID(distinctID),
+		Unset:            b.client.redactKeys(unset),
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Delete adds a $delete update, see PeopleDeleteProfile
+func (b *PeopleBatch) Delete(distinctID string, ignoreAlias bool) *PeopleBatch {
+	return b.deleteStored(distinctID, b.client.redactID(distinctID), ignoreAlias)
+}
+
+// deleteStored adds a $delete update for the stored distinct id, it is not redacted again
+// distinctID is the id reported in a PeopleBatchError
+func (b *PeopleBatch) deleteStored(distinctID, storedID string, ignoreAlias bool) *PeopleBatch {
+	return b.add(distinctID, profileOperationDelete, peopleDeleteProfilePayload{
+		Token:            b.client.token,
+		DistinctID:       storedID,
+		Delete:           "null",
+		IgnoreAlias:      strconv.FormatBool(ignoreAlias),
+		profileModifiers: b.options().modifiers(b.client.redaction),
+	})
+}
+
+// Flush sends the updates and empties the batch
+// Updates without a distinct_id or with invalid values are not sent, every update of a request that failed is reported in a PeopleBatchError
+func (b *PeopleBatch) Flush(ctx context.Context) error {
+	failed := b.client.flushProfileUpdates(ctx, b.take(), EndpointPeople, peopleBatchURL)
+	if len(failed) == 0 {
+		return nil
+	}
+
+	records := make([]PeopleRecordError, len(failed))
+	for i, f := range failed {
+		records[i] = PeopleRecordError{
+			Index:      f.index,
+			DistinctID: f.update.id,
+			Operation:  f.update.operation,
+			Err:        f.err,
+		}
+	}
+	return PeopleBatchError{Records: records}
+}
+
+type failedProfileUpdate struct {
+	index  int
+	update profileUpdate
+	err    error
+}
+
+// flushProfileUpdates sends the updates in order, one request at a time, and returns the failed updates sorted by index
+func (a *ApiClient) flushProfileUpdates(ctx context.Context, updates []profileUpdate, endpoint EndpointFamily, u string) []failedProfileUpdate {
+	var (
+		failed  []failedProfileUpdate
+		valid   []profileUpdate
+		indexes []int
+	)
+	for i, update := range updates {
+		if update.err != nil {
+			failed = append(failed, failedProfileUpdate{index: i, update: update, err: update.err})
+			continue
+		}
+		valid = append(valid, update)
+		indexes = append(indexes, i)
+	}
+
+	chunkErrors := sendChunks(ctx, splitChunks(len(valid), MaxPeopleEvents), 1, func(ctx context.Context, c eventChunk) error {
+		chunkEndpoint := endpoint
+		payloads := make([]any, 0, c.end-c.start)
+		for _, update := range valid[c.start:c.end] {
+			// $add and $append are not safe to retry
+			if update.operation == profileOperationAdd || update.operation == profileOperationAppend {
+				chunkEndpoint = EndpointPeopleIncrement
+			}
+			payloads = append(payloads, update.payload)
+		}
+		return a.doPeopleRequest(ctx, chunkEndpoint, payloads, u)
+	})
+	for _, chunkError := range chunkErrors {
+		for i := chunkError.Offset; i < chunkError.Offset+chunkError.Size; i++ {
+			failed = append(failed, failedProfileUpdate{index: indexes[i], update: valid[i], err: chunkError.Err})
+		}
+	}
+
+	sort.Slice(failed, func(i, j int) bool {
+		return failed[i].index < failed[j].index
+	})
+	return failed
+}
@@ -0,0 +1,138 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeopleBatch(t *testing.T) {
+	setupEngageEndpoint := func(t *testing.T, client *ApiClient, responder func(records []map[string]any) string) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, peopleBatchURL), func(req *http.Request) (*http.Response, error) {
+			require.Equal(t, "application/json", req.Header.Get("content-type"))
+			var records []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&records))
+			return httpmock.NewStringResponse(http.StatusOK, responder(records)), nil
+		})
+	}
+
+	t.Run("sends mixed operations in order", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+
+		var sent []map[string]any
+		setupEngageEndpoint(t, mp, func(records []map[string]any) string {
+			sent = records
+			return "1"
+		})
+
+		batch := mp.NewPeopleBatch().
+			Set(NewPeopleProperties("user-1", map[string]any{"plan": "pro"})).
+			SetOnce(NewPeopleProperties("user-1", map[string]any{"first_seen": "2024-01-01"})).
+			Increment("user-2", map[string]int{"logins": 1}).
+			Union("user-2", map[string]any{"tags": []string{"a"}}).
+			Append("user-3", map[string]any{"history": "x"}).
+			Remove("user-3", map[string]any{"history": "y"}).
+			Unset("user-4", []string{"plan"}).
+			Delete("user-5", true)
+		require.Equal(t, 8, batch.Len())
+
+		require.NoError(t, batch.Flush(ctx))
+		require.Equal(t, 0, batch.Len())
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+
+		require.Len(t, sent, 8)
+		for i, operation := range []string{"$set", "$set_once", "$add", "$union", "$append", "$remove", "$unset", "$delete"} {
+			require.Contains(t, sent[i], operation)
+			require.Equal(t, "token", sent[i]["$token"])
+		}
+		require.Equal(t, "user-2", sent[2]["$distinct_id"])
+		require.Equal(t, "true", sent[7]["$ignore_alias"])
+	})
+
+	t.Run("flushes in MaxPeopleEvents requests", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+
+		var sizes []int
+		setupEngageEndpoint(t, mp, func(records []map[string]any) string {
+			sizes = append(sizes, len(records))
+			return "1"
+		})
+
+		batch := mp.NewPeopleBatch()
+		for i := 0; i < MaxPeopleEvents+1; i++ {
+			batch.Unset(fmt.Sprintf("user-%d", i), []string{"plan"})
+		}
+		require.NoError(t, batch.Flush(ctx))
+		require.Equal(t, []int{MaxPeopleEvents, 1}, sizes)
+	})
+
+	t.Run("reports the records that failed", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+
+		requests := 0
+		setupEngageEndpoint(t, mp, func(records []map[string]any) string {
+			requests++
+			if requests == 2 {
+				return "0"
+			}
+			return "1"
+		})
+
+		batch := mp.NewPeopleBatch()
+		for i := 0; i < MaxPeopleEvents+2; i++ {
+			batch.Unset(fmt.Sprintf("user-%d", i), []string{"plan"})
+		}
+		batch.Increment(EmptyDistinctID, map[string]int{"logins": 1})
+		batch.Add("user-1", map[string]any{"revenue": "12,50"})
+
+		err := batch.Flush(ctx)
+		batchError := PeopleBatchError{}
+		require.ErrorAs(t, err, &batchError)
+		require.Len(t, batchError.Records, 4)
+		require.Equal(t, PeopleRecordError{
+			Index:      MaxPeopleEvents,
+			DistinctID: fmt.Sprintf("user-%d", MaxPeopleEvents),
+			Operation:  "$unset",
+			Err:        batchError.Records[0].Err,
+		}, batchError.Records[0])
+		require.EqualError(t, batchError.Records[0].Err, "api return code 0")
+		require.Equal(t, MaxPeopleEvents+1, batchError.Records[1].Index)
+		require.Equal(t, MaxPeopleEvents+2, batchError.Records[2].Index)
+		require.Equal(t, "$add", batchError.Records[2].Operation)
+		require.EqualError(t, batchError.Records[2].Err, "distinct_id is empty")
+		require.Equal(t, "user-1", batchError.Records[3].DistinctID)
+		require.EqualError(t, batchError.Records[3].Err, `invalid increment for "revenue": "12,50" is not a decimal number`)
+	})
+
+	t.Run("requests with $add or $append are not retried", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithRetryPolicy(RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, RetryOn: RetryOnServerError, Endpoints: EndpointIdempotent}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, peopleBatchURL), httpmock.NewStringResponder(http.StatusInternalServerError, ""))
+
+		require.Error(t, mp.NewPeopleBatch().Unset("user-1", []string{"plan"}).Flush(ctx))
+		require.Equal(t, 3, httpmock.GetTotalCallCount())
+
+		httpmock.ZeroCallCounters()
+		require.Error(t, mp.NewPeopleBatch().Unset("user-1", []string{"plan"}).Increment("user-1", map[string]int{"logins": 1}).Flush(ctx))
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+
+		httpmock.ZeroCallCounters()
+		require.Error(t, mp.NewPeopleBatch().Unset("user-1", []string{"plan"}).Append("user-1", map[string]any{"history": "x"}).Flush(ctx))
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
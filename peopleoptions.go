@@ -0,0 +1,55 @@
+package mixpanel
+
+import (
+	"net"
+)
+
+// PeopleUpdateOptions are the modifiers sent with every people and group update
+// https://developer.mixpanel.com/reference/profile-set
+type PeopleUpdateOptions struct {
+	// IgnoreTime does not update the $last_seen of the profile, use it for backfills
+	IgnoreTime bool
+	// IP is used to geolocate the profile, the ip of PeopleProperties.SetIp takes precedence for set and set once
+	IP net.IP
+	// Latitude and Longitude set the location of the profile instead of geolocating an ip
+	Latitude  *float64
+	Longitude *float64
+}
+
+// WithPeopleUpdateOptions applies the options to every people and group update sent by the client
+func WithPeopleUpdateOptions(options PeopleUpdateOptions) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.peopleUpdateOptions = options
+	}
+}
+
+// profileModifiers is embedded in the people and group payloads
+type profileModifiers struct {
+	IP         string   `json:"$ip,omitempty"`
+	IgnoreTime bool     `json:"$ignore_time,omitempty"`
+	Latitude   *float64 `json:"$latitude,omitempty"`
+	Longitude  *float64 `json:"$longitude,omitempty"`
+}
+
+// modifiers sends the ip of the options as "0" when the redaction policy drops or changes $ip
+func (o PeopleUpdateOptions) modifiers(r *redactor) profileModifiers {
+	m := profileModifiers{
+		IgnoreTime: o.IgnoreTime,
+		Latitude:   o.Latitude,
+		Longitude:  o.Longitude,
+	}
+	if o.IP != nil {
+		m.IP = r.ip(o.IP.String())
+	}
+	return m
+}
+
+// peopleModifiers uses the ip of the people properties when one was set, the ip of the options otherwise
+// p are the people properties before the redaction
+func (o PeopleUpdateOptions) peopleModifiers(p *PeopleProperties, r *redactor) profileModifiers {
+	m := o.modifiers(r)
+	if _, ok := p.Properties[string(PeopleGeolocationByIpProperty)]; ok || p.UseRequestIp || m.IP == "" {
+		m.IP = r.ip(p.shouldGeoLookupIp())
+	}
+	return m
+}
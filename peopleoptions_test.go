@@ -0,0 +1,155 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPeopleUpdateOptions(t *testing.T) {
+	latitude, longitude := 37.77, -122.42
+
+	setupProfileEndpoints := func(t *testing.T, client *ApiClient) *[]map[string]any {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var records []map[string]any
+		responder := func(req *http.Request) (*http.Response, error) {
+			var payloads []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payloads))
+			records = append(records, payloads...)
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		}
+		for _, u := range []string{peopleSetURL, peopleBatchURL, peopleIncrementUrl, peopleUnionToListUrl, peopleDeletePropertyUrl, groupSetUrl, groupsDeleteGroupUrl} {
+			httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, u), responder)
+		}
+		return &records
+	}
+
+	t.Run("applied to every people and group update", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithPeopleUpdateOptions(PeopleUpdateOptions{
+			IgnoreTime: true,
+			IP:         net.ParseIP("10.0.0.1"),
+			Latitude:   &latitude,
+			Longitude:  &longitude,
+		}))
+		records := setupProfileEndpoints(t, mp)
+
+		require.NoError(t, mp.PeopleSet(ctx, []*PeopleProperties{NewPeopleProperties("user-1", map[string]any{"plan": "pro"})}))
+		require.NoError(t, mp.PeopleAdd(ctx, "user-1", map[string]any{"logins": 1}))
+		require.NoError(t, mp.PeopleDeleteProperty(ctx, "user-1", []string{"plan"}))
+		require.NoError(t, mp.GroupSet(ctx, "company", "1", map[string]any{"plan": "pro"}))
+		require.NoError(t, mp.GroupDelete(ctx, "company", "1"))
+
+		require.Len(t, *records, 5)
+		for _, record := range *records {
+			require.Equal(t, true, record["$ignore_time"])
+			require.Equal(t, "10.0.0.1", record["$ip"])
+			require.Equal(t, latitude, record["$latitude"])
+			require.Equal(t, longitude, record["$longitude"])
+		}
+	})
+
+	t.Run("not sent by default", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		records := setupProfileEndpoints(t, mp)
+
+		require.NoError(t, mp.PeopleSet(ctx, []*PeopleProperties{NewPeopleProperties("user-1", nil)}))
+		require.NoError(t, mp.PeopleUnionProperty(ctx, "user-1", map[string]any{"tags": []string{"a"}}))
+
+		require.Len(t, *records, 2)
+		require.Equal(t, "0", (*records)[0]["$ip"])
+		require.NotContains(t, (*records)[1], "$ip")
+		for _, record := range *records {
+			require.NotContains(t, record, "$ignore_time")
+			require.NotContains(t, record, "$latitude")
+			require.NotContains(t, record, "$longitude")
+		}
+	})
+
+	t.Run("the ip of the people properties takes precedence", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithPeopleUpdateOptions(PeopleUpdateOptions{IP: net.ParseIP("10.0.0.1")}))
+		records := setupProfileEndpoints(t, mp)
+
+		withIp := NewPeopleProperties("user-1", nil)
+		withIp.SetIp(net.ParseIP("127.0.0.1"))
+		requestIp := NewPeopleProperties("user-2", nil)
+		requestIp.SetIp(nil, UseRequestIp())
+
+		require.NoError(t, mp.PeopleSet(ctx, []*PeopleProperties{withIp, requestIp, NewPeopleProperties("user-3", nil)}))
+		require.Equal(t, "127.0.0.1", (*records)[0]["$ip"])
+		require.NotContains(t, (*records)[1], "$ip")
+		require.Equal(t, "10.0.0.1", (*records)[2]["$ip"])
+	})
+
+	t.Run("redacted ips are not sent", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token",
+			WithPeopleUpdateOptions(PeopleUpdateOptions{IP: net.ParseIP("10.0.0.1")}),
+			WithRedaction(RedactionPolicy{Patterns: []*regexp.Regexp{IPv4Pattern}}),
+		)
+		records := setupProfileEndpoints(t, mp)
+
+		withIp := NewPeopleProperties("user-1", nil)
+		withIp.SetIp(net.ParseIP("127.0.0.1"))
+
+		require.NoError(t, mp.PeopleSet(ctx, []*PeopleProperties{withIp, NewPeopleProperties("user-2", nil)}))
+		require.NoError(t, mp.PeopleAdd(ctx, "user-1", map[string]any{"logins": 1}))
+		require.NoError(t, mp.NewPeopleBatch().Set(withIp).Flush(ctx))
+
+		require.Len(t, *records, 4)
+		for _, record := range *records {
+			require.Equal(t, "0", record["$ip"])
+		}
+	})
+
+	t.Run("dropped ips are not sent", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token",
+			WithPeopleUpdateOptions(PeopleUpdateOptions{IP: net.ParseIP("10.0.0.1")}),
+			WithRedaction(RedactionPolicy{DropKeys: []string{"$ip"}}),
+		)
+		records := setupProfileEndpoints(t, mp)
+
+		require.NoError(t, mp.GroupSet(ctx, "company", "1", map[string]any{"plan": "pro"}))
+		require.Equal(t, "0", (*records)[0]["$ip"])
+	})
+
+	t.Run("batch options", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithPeopleUpdateOptions(PeopleUpdateOptions{IgnoreTime: true}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		var records []map[string]any
+		responder := func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&records))
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		}
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, peopleBatchURL), responder)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, groupsBatchURL), responder)
+
+		require.NoError(t, mp.NewPeopleBatch().
+			Unset("user-1", []string{"plan"}).
+			WithOptions(PeopleUpdateOptions{Latitude: &latitude, Longitude: &longitude}).
+			Unset("user-2", []string{"plan"}).
+			Flush(ctx))
+		require.Equal(t, true, records[0]["$ignore_time"])
+		require.NotContains(t, records[0], "$latitude")
+		require.NotContains(t, records[1], "$ignore_time")
+		require.Equal(t, latitude, records[1]["$latitude"])
+
+		require.NoError(t, mp.NewGroupBatch().Unset("company", "1", []string{"plan"}).Flush(ctx))
+		require.Equal(t, true, records[0]["$ignore_time"])
+	})
+}
@@ -0,0 +1,266 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	engageQueryUrl = "/api/2.0/engage"
+)
+
+// Profile is a user profile returned by QueryProfiles
+type Profile struct {
+	DistinctID string         `json:"$distinct_id"`
+	Properties map[string]any `json:"$properties"`
+}
+
+// ProfileQuery filters the profiles returned by QueryProfiles and QueryGroupProfiles, the zero value returns every profile
+// https://developer.mixpanel.com/reference/engage-query
+type ProfileQuery struct {
+	// Where is a segmentation expression, like properties["plan"] == "pro"
+	Where string
+	// OutputProperties limits the properties returned for every profile
+	OutputProperties []string
+	// DistinctIDs returns the profiles of the distinct ids
+	DistinctIDs []string
+	// CohortID returns the profiles in the cohort
+	CohortID int
+}
+
+// filtered reports if the query does not match every profile
+func (q ProfileQuery) filtered() bool {
+	return q.Where != "" || len(q.DistinctIDs) > 0 || q.CohortID != 0
+}
+
+func (q ProfileQuery) form() (url.Values, error) {
+	form := url.Values{}
+	if q.Where != "" {
+		form.Set("where", q.Where)
+	}
+	if len(q.OutputProperties) > 0 {
+		properties, err := json.Marshal(q.OutputProperties)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("output_properties", string(properties))
+	}
+	if len(q.DistinctIDs) > 0 {
+		distinctIDs, err := json.Marshal(q.DistinctIDs)
+		if err != nil {
+			return nil, err
+		}
+		form.Set("distinct_ids", string(distinctIDs))
+	}
+	if q.CohortID != 0 {
+		form.Set("filter_by_cohort", fmt.Sprintf(`{"id":%d}`, q.CohortID))
+	}
+	return form, nil
+}
+
+type engageQueryResponse struct {
+	Page      int        `json:"page"`
+	PageSize  int        `json:"page_size"`
+	SessionID string     `json:"session_id"`
+	Status    string     `json:"status"`
+	Total     int        `json:"total"`
+	Results   []*Profile `json:"results"`
+}
+
+// ProfileIterator pages through the profiles of a query
+//
+//	for profiles.Next() {
+//		profile := profiles.Profile()
+//	}
+//	if err := profiles.Err(); err != nil {
+//	}
+type ProfileIterator struct {
+	ctx    context.Context
+	client *ApiClient
+	form   url.Values
+
+	profiles  []*Profile
+	index     int
+	page      int
+	sessionID string
+	total     int
+	fetched   int
+	last      bool
+	err       error
+}
+
+// QueryProfiles calls the Engage Query API and returns an iterator over the matching profiles
+// The first page is requested before returning, the next pages are requested by Next
+// https://developer.mixpanel.com/reference/engage-query
+func (a *ApiClient) QueryProfiles(ctx context.Context, query ProfileQuery) (*ProfileIterator, error) {
+	form, err := query.form()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query: %w", err)
+	}
+	return a.queryProfiles(ctx, form)
+}
+
+func (a *ApiClient) queryProfiles(ctx context.Context, form url.Values) (*ProfileIterator, error) {
+	it := &ProfileIterator{
+		ctx:    ctx,
+		client: a,
+		form:   form,
+		index:  -1,
+	}
+	if err := it.fetch(); err != nil {
+		return nil, err
+	}
+	return it, nil
+}
+
+// Next moves to the next profile, it returns false after the last profile or when a page failed
+func (it *ProfileIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.index++
+	for it.index >= len(it.profiles) {
+		if it.last {
+			return false
+		}
+		if it.err = it.fetch(); it.err != nil {
+			return false
+		}
+		it.index = 0
+	}
+	return true
+}
+
+// Profile returns the current profile
+func (it *ProfileIterator) Profile() *Profile {
+	if it.index < 0 || it.index >= len(it.profiles) {
+		return nil
+	}
+	return it.profiles[it.index]
+}
+
+// Err returns the error of the page that failed
+func (it *ProfileIterator) Err() error {
+	return it.err
+}
+
+// Total returns the number of profiles matching the query
+func (it *ProfileIterator) Total() int {
+	return it.total
+}
+
+func (it *ProfileIterator) fetch() error {
+	form := url.Values{}
+	for key, values := range it.form {
+		form[key] = values
+	}
+	if it.sessionID != "" {
+		form.Set("session_id", it.sessionID)
+		form.Set("page", strconv.Itoa(it.page+1))
+	}
+
+	response, err := it.client.doRequestBody(
+		it.ctx,
+		EndpointQuery,
+		http.MethodPost,
+		it.client.queryEndpoint+engageQueryUrl,
+		strings.NewReader(form.Encode()),
+		it.client.exportServiceAccount(), acceptJson(), applicationFormData(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query profiles: %w", err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return newHttpError(response.StatusCode, response.Body)
+	}
+
+	var r engageQueryResponse
+	if err := json.NewDecoder(response.Body).Decode(&r); err != nil {
+		return fmt.Errorf("failed to decode profiles: %w", err)
+	}
+
+	if it.sessionID == "" {
+		it.total = r.Total
+	}
+	it.page = r.Page
+	it.sessionID = r.SessionID
+	it.profiles = r.Results
+	it.fetched += len(r.Results)
+	it.last = r.SessionID == "" || len(r.Results) == 0 || len(r.Results) < r.PageSize || (it.total > 0 && it.fetched >= it.total)
+	return nil
+}
+
+// GroupProfile is a group profile returned by QueryGroupProfiles
+type GroupProfile struct {
+	DataGroupID string
+	GroupID     string
+	Properties  map[string]any
+}
+
+// GroupProfileIterator pages through the group profiles of a query, like ProfileIterator
+type GroupProfileIterator struct {
+	dataGroupID string
+	profiles    *ProfileIterator
+}
+
+// QueryGroupProfiles calls the Engage Query API for the profiles of a group key and returns an iterator over the matching groups
+// dataGroupID is the id of the group key shown in the project settings, not the group key name used by GroupSet
+// The DistinctIDs of the query are group ids
+// https://developer.mixpanel.com/reference/engage-query
+func (a *ApiClient) QueryGroupProfiles(ctx context.Context, dataGroupID string, query ProfileQuery) (*GroupProfileIterator, error) {
+	if dataGroupID == "" {
+		return nil, errors.New("data group id is empty")
+	}
+
+	form, err := query.form()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode query: %w", err)
+	}
+	form.Set("data_group_id", dataGroupID)
+
+	profiles, err := a.queryProfiles(ctx, form)
+	if err != nil {
+		return nil, err
+	}
+	return &GroupProfileIterator{
+		dataGroupID: dataGroupID,
+		profiles:    profiles,
+	}, nil
+}
+
+// Next moves to the next group profile, it returns false after the last profile or when a page failed
+func (it *GroupProfileIterator) Next() bool {
+	return it.profiles.Next()
+}
+
+// Profile returns the current group profile
+func (it *GroupProfileIterator) Profile() *GroupProfile {
+	profile := it.profiles.Profile()
+	if profile == nil {
+		return nil
+	}
+	return &GroupProfile{
+		DataGroupID: it.dataGroupID,
+		GroupID:     profile.DistinctID,
+		Properties:  profile.Properties,
+	}
+}
+
+// Err returns the error of the page that failed
+func (it *GroupProfileIterator) Err() error {
+	return it.profiles.Err()
+}
+
+// Total returns the number of group profiles matching the query
+func (it *GroupProfileIterator) Total() int {
+	return it.profiles.Total()
+}
@@ -0,0 +1,195 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+// setupEngageQueryEndpoint serves the pages of profiles, the form of every request is passed to check
+func setupEngageQueryEndpoint(t *testing.T, client *ApiClient, pages [][]*Profile, check func(page int, req *http.Request)) {
+	httpmock.Activate()
+	t.Cleanup(httpmock.DeactivateAndReset)
+
+	total := 0
+	for _, page := range pages {
+		total += len(page)
+	}
+
+	requests := 0
+	httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.queryEndpoint, engageQueryUrl), func(req *http.Request) (*http.Response, error) {
+		require.Equal(t, contentTypeApplicationForm, req.Header.Get(contentTypeHeader))
+		require.NoError(t, req.ParseForm())
+
+		page := requests
+		requests++
+		if check != nil {
+			check(page, req)
+		}
+
+		response := engageQueryResponse{
+			Page:      page,
+			PageSize:  2,
+			SessionID: "session",
+			Status:    "ok",
+			Total:     total,
+			Results:   pages[page],
+		}
+		return httpmock.NewJsonResponse(http.StatusOK, response)
+	})
+}
+
+func TestQueryProfiles(t *testing.T) {
+	pages := [][]*Profile{
+		{
+			{DistinctID: "user-1", Properties: map[string]any{"plan": "pro"}},
+			{DistinctID: "user-2", Properties: map[string]any{"plan": "pro"}},
+		},
+		{
+			{DistinctID: "user-3", Properties: map[string]any{"plan": "free"}},
+		},
+	}
+
+	t.Run("pages through every profile", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ServiceAccount(117, "username", "secret"))
+		setupEngageQueryEndpoint(t, mp, pages, func(page int, req *http.Request) {
+			username, password, ok := req.BasicAuth()
+			require.True(t, ok)
+			require.Equal(t, "username", username)
+			require.Equal(t, "secret", password)
+			require.Equal(t, "117", req.URL.Query().Get("project_id"))
+
+			require.Equal(t, `properties["plan"] != "enterprise"`, req.PostForm.Get("where"))
+			require.Equal(t, `["plan"]`, req.PostForm.Get("output_properties"))
+			require.Equal(t, `{"id":42}`, req.PostForm.Get("filter_by_cohort"))
+			if page == 0 {
+				require.False(t, req.PostForm.Has("session_id"))
+			} else {
+				require.Equal(t, "session", req.PostForm.Get("session_id"))
+				require.Equal(t, "1", req.PostForm.Get("page"))
+			}
+		})
+
+		profiles, err := mp.QueryProfiles(ctx, ProfileQuery{
+			Where:            `properties["plan"] != "enterprise"`,
+			OutputProperties: []string{"plan"},
+			CohortID:         42,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, profiles.Total())
+
+		var distinctIDs []string
+		for profiles.Next() {
+			distinctIDs = append(distinctIDs, profiles.Profile().DistinctID)
+		}
+		require.NoError(t, profiles.Err())
+		require.Equal(t, []string{"user-1", "user-2", "user-3"}, distinctIDs)
+		require.Equal(t, 2, httpmock.GetTotalCallCount())
+		require.False(t, profiles.Next())
+	})
+
+	t.Run("distinct ids lookup with the api secret", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupEngageQueryEndpoint(t, mp, pages[1:], func(page int, req *http.Request) {
+			username, _, ok := req.BasicAuth()
+			require.True(t, ok)
+			require.Equal(t, "api-secret", username)
+
+			var distinctIDs []string
+			require.NoError(t, json.Unmarshal([]byte(req.PostForm.Get("distinct_ids")), &distinctIDs))
+			require.Equal(t, []string{"user-3"}, distinctIDs)
+		})
+
+		profiles, err := mp.QueryProfiles(ctx, ProfileQuery{DistinctIDs: []string{"user-3"}})
+		require.NoError(t, err)
+		require.True(t, profiles.Next())
+		require.Equal(t, "free", profiles.Profile().Properties["plan"])
+		require.False(t, profiles.Next())
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.queryEndpoint, engageQueryUrl), httpmock.NewStringResponder(http.StatusUnauthorized, `{"error": "unauthorized"}`))
+
+		_, err := mp.QueryProfiles(ctx, ProfileQuery{})
+		httpError := HttpError{}
+		require.ErrorAs(t, err, &httpError)
+		require.Equal(t, http.StatusUnauthorized, httpError.Status)
+	})
+}
+
+func TestQueryGroupProfiles(t *testing.T) {
+	t.Run("pages through the group profiles", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		pages := [][]*Profile{
+			{
+				{DistinctID: "acme", Properties: map[string]any{"plan": "pro"}},
+				{DistinctID: "globex", Properties: map[string]any{"plan": "pro"}},
+			},
+			{},
+		}
+		setupEngageQueryEndpoint(t, mp, pages, func(page int, req *http.Request) {
+			require.Equal(t, "7", req.PostForm.Get("data_group_id"))
+			require.Equal(t, `properties["plan"] == "pro"`, req.PostForm.Get("where"))
+		})
+
+		groups, err := mp.QueryGroupProfiles(ctx, "7", ProfileQuery{Where: `properties["plan"] == "pro"`})
+		require.NoError(t, err)
+		require.Equal(t, 2, groups.Total())
+
+		var profiles []*GroupProfile
+		for groups.Next() {
+			profiles = append(profiles, groups.Profile())
+		}
+		require.NoError(t, groups.Err())
+		require.Equal(t, []*GroupProfile{
+			{DataGroupID: "7", GroupID: "acme", Properties: map[string]any{"plan": "pro"}},
+			{DataGroupID: "7", GroupID: "globex", Properties: map[string]any{"plan": "pro"}},
+		}, profiles)
+	})
+
+	t.Run("a failed page stops the iterator", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		requests := 0
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.queryEndpoint, engageQueryUrl), func(req *http.Request) (*http.Response, error) {
+			requests++
+			if requests > 1 {
+				return httpmock.NewStringResponse(http.StatusInternalServerError, "error"), nil
+			}
+			return httpmock.NewJsonResponse(http.StatusOK, engageQueryResponse{
+				PageSize:  1,
+				SessionID: "session",
+				Total:     2,
+				Results:   []*Profile{{DistinctID: "acme"}},
+			})
+		})
+
+		groups, err := mp.QueryGroupProfiles(ctx, "7", ProfileQuery{})
+		require.NoError(t, err)
+		require.True(t, groups.Next())
+		require.Equal(t, "acme", groups.Profile().GroupID)
+		require.False(t, groups.Next())
+		require.Error(t, groups.Err())
+		require.Nil(t, groups.Profile())
+
+		_, err = mp.QueryGroupProfiles(ctx, "", ProfileQuery{})
+		require.Error(t, err)
+	})
+}
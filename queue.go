@@ -0,0 +1,245 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueueBufferSize    = 10_000
+	defaultQueueFlushInterval = 5 * time.Second
+)
+
+var (
+	ErrQueueNotConfigured = errors.New("event queue is not configured, use the WithEventQueue option")
+	ErrQueueFull          = errors.New("event queue is full")
+	ErrQueueClosed        = errors.New("event queue is closed")
+)
+
+// QueueConfig configures the background event queue
+type QueueConfig struct {
+	// BatchSize is the max number of events sent in a single request
+	// it is capped to MaxTrackEvents
+	BatchSize int
+	// FlushInterval is how often buffered events are sent even if the batch is not full
+	FlushInterval time.Duration
+	// BufferSize is how many events can be waiting in memory before Enqueue returns ErrQueueFull
+	BufferSize int
+
+	// UseImport sends the batches with the Import api instead of the Track api
+	UseImport     bool
+	ImportOptions ImportOptions
+
+	// OnError is called with every batch that failed to be sent
+	OnError func(events []*Event, err error)
+}
+
+// DefaultQueueConfig returns the recommended queue configuration
+func DefaultQueueConfig() QueueConfig {
+	return QueueConfig{
+		BatchSize:     MaxTrackEvents,
+		FlushInterval: defaultQueueFlushInterval,
+		BufferSize:    defaultQueueBufferSize,
+		ImportOptions: ImportOptionsRecommend,
+	}
+}
+
+// WithEventQueue enables the background event queue used by Enqueue
+// Call Close before exiting to send the remaining events
+func WithEventQueue(config QueueConfig) Options {
+	return func(mixpanel *ApiClient) {
+		if config.BatchSize <= 0 || config.BatchSize > MaxTrackEvents {
+			config.BatchSize = MaxTrackEvents
+		}
+		if config.FlushInterval <= 0 {
+			config.FlushInterval = defaultQueueFlushInterval
+		}
+		if config.BufferSize <= 0 {
+			config.BufferSize = defaultQueueBufferSize
+		}
+
+		mixpanel.queue = &eventQueue{
+			client:  mixpanel,
+			config:  config,
+			events:  make(chan *Event, config.BufferSize),
+			flushes: make(chan queueFlushRequest),
+			done:    make(chan struct{}),
+		}
+	}
+}
+
+type queueFlushRequest struct {
+	ctx   context.Context
+	final bool
+	reply chan error
+}
+
+type eventQueue struct {
+	client *ApiClient
+	config QueueConfig
+
+	events  chan *Event
+	flushes chan queueFlushRequest
+	done    chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+}
+
+func (q *eventQueue) start() {
+	go q.run()
+}
+
+func (q *eventQueue) run() {
+	defer close(q.done)
+
+	ticker := time.NewTicker(q.config.FlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Event, 0, q.config.BatchSize)
+	for {
+		select {
+		case event := <-q.events:
+			batch = append(batch, event)
+			if len(batch) >= q.config.BatchSize {
+				_ = q.send(context.Background(), batch)
+				batch = make([]*Event, 0, q.config.BatchSize)
+			}
+		case <-ticker.C:
+			_ = q.send(context.Background(), batch)
+			batch = make([]*Event, 0, q.config.BatchSize)
+		case request := <-q.flushes:
+			// Everything enqueued before the flush was requested is already in the channel
+			for pending := len(q.events); pending > 0; pending-- {
+				batch = append(batch, <-q.events)
+			}
+			request.reply <- q.send(request.ctx, batch)
+			batch = make([]*Event, 0, q.config.BatchSize)
+
+			if request.final {
+				return
+			}
+		}
+	}
+}
+
+// send sends the events in chunks of BatchSize and returns the first error
+func (q *eventQueue) send(ctx context.Context, events []*Event) error {
+	var firstErr error
+	for start := 0; start < len(events); start += q.config.BatchSize {
+		end := start + q.config.BatchSize
+		if end > len(events) {
+			end = len(events)
+		}
+
+		chunk := events[start:end]
+		var err error
+		if q.config.UseImport {
+			_, err = q.client.Import(ctx, chunk, q.config.ImportOptions)
+		} else {
+			err = q.client.Track(ctx, chunk)
+		}
+
+		if err != nil {
+			if q.config.OnError != nil {
+				q.config.OnError(chunk, err)
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+func (q *eventQueue) enqueue(event *Event) error {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+
+	if q.closed {
+		return ErrQueueClosed
+	}
+
+	select {
+	case q.events <- event:
+		return nil
+	default:
+		return ErrQueueFull
+	}
+}
+
+func (q *eventQueue) flush(ctx context.Context, final bool) error {
+	// the run loop must not accept a flush it can't send
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	request := queueFlushRequest{
+		ctx:   ctx,
+		final: final,
+		reply: make(chan error, 1),
+	}
+
+	select {
+	case q.flushes <- request:
+	case <-q.done:
+		return ErrQueueClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case err := <-request.reply:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close stops accepting events and sends the final flush
+// It can be called again until the run loop accepted the final flush, after that it returns ErrQueueClosed
+func (q *eventQueue) close(ctx context.Context) error {
+	q.mu.Lock()
+	q.closed = true
+	q.mu.Unlock()
+
+	return q.flush(ctx, true)
+}
+
+// Enqueue adds the event to the background queue without waiting for it to be sent
+// Events are sent in batches when the batch is full or when the flush interval elapses
+func (m *ApiClient) Enqueue(event *Event) error {
+	if m.queue == nil {
+		return ErrQueueNotConfigured
+	}
+	if event == nil {
+		return errors.New("event is nil")
+	}
+	return m.queue.enqueue(event)
+}
+
+// Flush sends all the events that have been enqueued so far and waits for them to be sent
+func (m *ApiClient) Flush(ctx context.Context) error {
+	if m.queue == nil {
+		return nil
+	}
+	return m.queue.flush(ctx, false)
+}
+
+// Close sends the remaining enqueued events, stops the background queue and closes the spool
+// Enqueue returns ErrQueueClosed after Close is called
+// If ctx is done before the events are sent Close can be called again to send them
+func (m *ApiClient) Close(ctx context.Context) error {
+	var err error
+	if m.queue != nil {
+		err = m.queue.close(ctx)
+	}
+	if m.spool != nil {
+		if spoolErr := m.spool.close(); spoolErr != nil && err == nil {
+			err = spoolErr
+		}
+	}
+	return err
+}
@@ -0,0 +1,167 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventQueue(t *testing.T) {
+	setupQueueTrackEndpoint := func(t *testing.T, client *ApiClient, status int, body string) *[][]*Event {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var mu sync.Mutex
+		var batches [][]*Event
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			var r []*Event
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&r))
+
+			mu.Lock()
+			batches = append(batches, r)
+			mu.Unlock()
+
+			return httpmock.NewStringResponse(status, body), nil
+		})
+		return &batches
+	}
+
+	trackSuccessBody := `{"error": "", "status": 1}`
+
+	t.Run("enqueue without a queue configured", func(t *testing.T) {
+		mp := NewApiClient("token")
+		require.ErrorIs(t, mp.Enqueue(mp.NewEvent("event", EmptyDistinctID, nil)), ErrQueueNotConfigured)
+		require.NoError(t, mp.Flush(context.Background()))
+		require.NoError(t, mp.Close(context.Background()))
+	})
+
+	t.Run("flush sends enqueued events", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultQueueConfig()
+		config.FlushInterval = time.Hour
+		mp := NewApiClient("token", WithEventQueue(config))
+		batches := setupQueueTrackEndpoint(t, mp, http.StatusOK, trackSuccessBody)
+
+		for i := 0; i < 3; i++ {
+			require.NoError(t, mp.Enqueue(mp.NewEvent(fmt.Sprintf("event_%d", i), EmptyDistinctID, nil)))
+		}
+		require.NoError(t, mp.Flush(ctx))
+
+		require.Len(t, *batches, 1)
+		require.Len(t, (*batches)[0], 3)
+		require.Equal(t, "event_0", (*batches)[0][0].Name)
+		require.NoError(t, mp.Close(ctx))
+	})
+
+	t.Run("sends when the batch is full", func(t *testing.T) {
+		ctx := context.Background()
+		config := DefaultQueueConfig()
+		config.BatchSize = 2
+		config.FlushInterval = time.Hour
+		mp := NewApiClient("token", WithEventQueue(config))
+		batches := setupQueueTrackEndpoint(t, mp, http.StatusOK, trackSuccessBody)
+
+		for i := 0; i < 5; i++ {
+			require.NoError(t, mp.Enqueue(mp.NewEvent("event", EmptyDistinctID, nil)))
+		}
+		require.NoError(t, mp.Close(ctx))
+
+		require.Len(t, *batches, 3)
+		require.Len(t, (*batches)[0], 2)
+		require.Len(t, (*batches)[1], 2)
+		require.Len(t, (*batches)[2], 1)
+	})
+
+	t.Run("sends on the flush interval", func(t *testing.T) {
+		config := DefaultQueueConfig()
+		config.FlushInterval = 10 * time.Millisecond
+		mp := NewApiClient("token", WithEventQueue(config))
+		setupQueueTrackEndpoint(t, mp, http.StatusOK, trackSuccessBody)
+
+		require.NoError(t, mp.Enqueue(mp.NewEvent("event", EmptyDistinctID, nil)))
+		require.Eventually(t, func() bool {
+			return httpmock.GetTotalCallCount() == 1
+		}, time.Second, 5*time.Millisecond)
+		require.NoError(t, mp.Close(context.Background()))
+	})
+
+	t.Run("reports failed batches", func(t *testing.T) {
+		ctx := context.Background()
+		var failed []*Event
+		config := DefaultQueueConfig()
+		config.FlushInterval = time.Hour
+		config.OnError = func(events []*Event, err error) {
+			require.Error(t, err)
+			failed = append(failed, events...)
+		}
+		mp := NewApiClient("token", WithEventQueue(config))
+		setupQueueTrackEndpoint(t, mp, http.StatusOK, `{"error": "data, missing or empty", "status": 0}`)
+
+		require.NoError(t, mp.Enqueue(mp.NewEvent("event", EmptyDistinctID, nil)))
+		require.Error(t, mp.Flush(ctx))
+		require.Len(t, failed, 1)
+		require.NoError(t, mp.Close(ctx))
+	})
+
+	t.Run("enqueue fails when the buffer is full", func(t *testing.T) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		config := DefaultQueueConfig()
+		config.BatchSize = 1
+		config.BufferSize = 1
+		mp := NewApiClient("token", WithEventQueue(config))
+
+		// block the worker on its first request so the buffer can fill up
+		release := make(chan struct{})
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			<-release
+			return httpmock.NewStringResponse(http.StatusOK, trackSuccessBody), nil
+		})
+
+		var err error
+		for i := 0; i < 3 && err == nil; i++ {
+			err = mp.Enqueue(mp.NewEvent("event", EmptyDistinctID, nil))
+		}
+		require.ErrorIs(t, err, ErrQueueFull)
+
+		close(release)
+		require.NoError(t, mp.Close(context.Background()))
+	})
+
+	t.Run("enqueue fails after close", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithEventQueue(DefaultQueueConfig()))
+		setupQueueTrackEndpoint(t, mp, http.StatusOK, trackSuccessBody)
+
+		require.NoError(t, mp.Close(ctx))
+		require.ErrorIs(t, mp.Enqueue(mp.NewEvent("event", EmptyDistinctID, nil)), ErrQueueClosed)
+		require.ErrorIs(t, mp.Close(ctx), ErrQueueClosed)
+	})
+
+	t.Run("close can be retried after its context is done", func(t *testing.T) {
+		config := DefaultQueueConfig()
+		config.FlushInterval = time.Hour
+		mp := NewApiClient("token", WithEventQueue(config))
+		batches := setupQueueTrackEndpoint(t, mp, http.StatusOK, trackSuccessBody)
+		require.NoError(t, mp.Enqueue(mp.NewEvent("event", EmptyDistinctID, nil)))
+
+		canceled, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.ErrorIs(t, mp.Close(canceled), context.Canceled)
+		require.ErrorIs(t, mp.Enqueue(mp.NewEvent("event", EmptyDistinctID, nil)), ErrQueueClosed)
+		require.Empty(t, *batches)
+
+		require.NoError(t, mp.Close(context.Background()))
+		require.Len(t, *batches, 1)
+		require.Len(t, (*batches)[0], 1)
+		require.ErrorIs(t, mp.Close(context.Background()), ErrQueueClosed)
+	})
+}
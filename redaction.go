@@ -0,0 +1,311 @@
+package mixpanel
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const defaultRedactionReplacement = "[REDACTED]"
+
+var (
+	// EmailPattern matches email addresses
+	EmailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`)
+	// PhonePattern matches E.164 phone numbers and north american numbers written with separators
+	// Plain digit runs are not matched so timestamps and ids are left alone
+	PhonePattern = regexp.MustCompile(`\+\d{8,15}\b|(?:\+\d{1,3}[\s.\-]?)?\(?\b\d{3}\)?[\s.\-]\d{3}[\s.\-]\d{4}\b`)
+	// IPv4Pattern matches ipv4 addresses
+	IPv4Pattern = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`)
+)
+
+// redactionReservedProperties are always kept by the allowlist so events are still accepted by the api
+var redactionReservedProperties = map[string]bool{
+	propertyToken:      true,
+	propertyDistinctID: true,
+	propertyTime:       true,
+	propertyInsertID:   true,
+	propertyDeviceID:   true,
+	propertyUserID:     true,
+	propertyMpLib:      true,
+	propertyLibVersion: true,
+}
+
+// redactionIdentityProperties are hashed instead of scrubbed so a user keeps a single id in events and profiles
+var redactionIdentityProperties = map[string]bool{
+	propertyDistinctID: true,
+	propertyDeviceID:   true,
+	propertyUserID:     true,
+}
+
+// RedactionPolicy removes personal data from the properties before they are sent
+// Rules are applied in order: allowlist, drop, hash and then patterns
+type RedactionPolicy struct {
+	// Allowlist when not empty removes every top level property that is not in the list
+	// The token, distinct_id, time, $insert_id, $device_id, $user_id and library properties of events are always kept
+	Allowlist []string
+	// DropKeys are removed at any nesting level
+	DropKeys []string
+	// HashKeys are replaced by the hex sha256 of HashSalt and the value at any nesting level
+	HashKeys []string
+	HashSalt string
+	// Patterns are replaced by Replacement in every string value and in the DebugHttpCalls dump
+	// Distinct ids, $device_id, $user_id and group ids are hashed with HashSalt instead when they match a pattern,
+	// or always when distinct_id, $device_id or $user_id is in HashKeys, so events and profiles keep the same ids
+	Patterns []*regexp.Regexp
+	// Replacement defaults to [REDACTED]
+	Replacement string
+}
+
+type redactor struct {
+	allowlist   map[string]bool
+	drop        map[string]bool
+	hash        map[string]bool
+	salt        string
+	hashIDs     bool
+	patterns    []*regexp.Regexp
+	replacement string
+}
+
+// WithRedaction applies the policy to the properties of events, people profiles and groups sent by the client
+// The maps passed by the caller are not modified
+func WithRedaction(policy RedactionPolicy) Options {
+	return func(mixpanel *ApiClient) {
+		r := &redactor{
+			drop:        toSet(policy.DropKeys),
+			hash:        toSet(policy.HashKeys),
+			salt:        policy.HashSalt,
+			patterns:    append([]*regexp.Regexp(nil), policy.Patterns...),
+			replacement: policy.Replacement,
+		}
+		r.hashIDs = r.hash[propertyDistinctID] || r.hash[propertyDeviceID] || r.hash[propertyUserID]
+		if len(policy.Allowlist) > 0 {
+			r.allowlist = toSet(policy.Allowlist)
+		}
+		if r.replacement == "" {
+			r.replacement = defaultRedactionReplacement
+		}
+		mixpanel.redaction = r
+	}
+}
+
+func toSet(keys []string) map[string]bool {
+	set := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		set[key] = true
+	}
+	return set
+}
+
+// properties returns a redacted copy of the properties
+// reserved are the properties kept by the allowlist
+func (r *redactor) properties(properties map[string]any, reserved map[string]bool) map[string]any {
+	if r == nil || properties == nil {
+		return properties
+	}
+
+	redacted := make(map[string]any, len(properties))
+	for key, value := range properties {
+		if r.allowlist != nil && !r.allowlist[key] && !reserved[key] {
+			continue
+		}
+		// the project token is required and never personal data
+		if key == propertyToken && reserved[key] {
+			redacted[key] = value
+			continue
+		}
+		if id, ok := value.(string); ok && reserved[key] && redactionIdentityProperties[key] {
+			redacted[key] = r.identity(id)
+			continue
+		}
+		if value, ok := r.property(key, value); ok {
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
+
+// identity hashes the id when it is personal data, the same id is always hashed to the same value
+// Only the device id of a "$device:" distinct_id is hashed so it still matches the $device_id of the event
+func (r *redactor) identity(id string) string {
+	if r == nil || id == "" {
+		return id
+	}
+	if deviceID := strings.TrimPrefix(id, deviceIDPrefix); deviceID != id {
+		return deviceIDPrefix + r.identity(deviceID)
+	}
+	if r.hashIDs || r.scrub(id) != id {
+		return r.hashValue(id)
+	}
+	return id
+}
+
+// ip returns the $ip modifier of a profile update, "0" disables the geolocation when the policy drops or changes $ip
+func (r *redactor) ip(ip string) string {
+	if r == nil || ip == "" || ip == "0" {
+		return ip
+	}
+	key := string(PeopleGeolocationByIpProperty)
+	if r.removes(key) {
+		return "0"
+	}
+	if value, ok := r.property(key, ip); !ok || value != ip {
+		return "0"
+	}
+	return ip
+}
+
+// property returns false if the property must be dropped
+func (r *redactor) property(key string, value any) (any, bool) {
+	if r.drop[key] {
+		return nil, false
+	}
+	if r.hash[key] {
+		return r.hashValue(value), true
+	}
+	return r.value(value), true
+}
+
+func (r *redactor) value(value any) any {
+	switch v := value.(type) {
+	case string:
+		return r.scrub(v)
+	case []string:
+		scrubbed := make([]string, len(v))
+		for i, s := range v {
+			scrubbed[i] = r.scrub(s)
+		}
+		return scrubbed
+	case []any:
+		scrubbed := make([]any, len(v))
+		for i, nested := range v {
+			scrubbed[i] = r.value(nested)
+		}
+		return scrubbed
+	case map[string]any:
+		nested := make(map[string]any, len(v))
+		for key, value := range v {
+			if value, ok := r.property(key, value); ok {
+				nested[key] = value
+			}
+		}
+		return nested
+	default:
+		return value
+	}
+}
+
+func (r *redactor) scrub(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, r.replacement)
+	}
+	return s
+}
+
+func (r *redactor) hashValue(value any) string {
+	s, ok := value.(string)
+	if !ok {
+		data, err := json.Marshal(value)
+		if err != nil {
+			data = []byte(fmt.Sprint(value))
+		}
+		s = string(data)
+	}
+
+	hash := sha256.Sum256([]byte(r.salt + s))
+	return hex.EncodeToString(hash[:])
+}
+
+// dump scrubs the patterns from the DebugHttpCalls dump
+func (r *redactor) dump(dump []byte) []byte {
+	if r == nil {
+		return dump
+	}
+	for _, pattern := range r.patterns {
+		dump = pattern.ReplaceAll(dump, []byte(r.replacement))
+	}
+	return dump
+}
+
+// redactEvents returns copies of the events with the redaction policy applied
+func (m *ApiClient) redactEvents(events []*Event) []*Event {
+	if m.redaction == nil {
+		return events
+	}
+
+	redacted := make([]*Event, len(events))
+	for i, event := range events {
+		if event == nil {
+			continue
+		}
+		redacted[i] = &Event{
+			Name:       event.Name,
+			Properties: m.redaction.properties(event.Properties, redactionReservedProperties),
+		}
+	}
+	return redacted
+}
+
+// redactPeople returns a copy of the people properties with the redaction policy applied
+func (m *ApiClient) redactPeople(people *PeopleProperties) *PeopleProperties {
+	if m.redaction == nil || people == nil {
+		return people
+	}
+
+	redacted := *people
+	redacted.DistinctID = m.redaction.identity(people.DistinctID)
+	redacted.Properties = m.redaction.properties(people.Properties, nil)
+	return &redacted
+}
+
+// redactProperties returns a copy of the profile properties with the redaction policy applied
+func (m *ApiClient) redactProperties(properties map[string]any) map[string]any {
+	return m.redaction.properties(properties, nil)
+}
+
+// removes reports if the top level property is removed by the allowlist or the drop keys
+func (r *redactor) removes(key string) bool {
+	return (r.allowlist != nil && !r.allowlist[key]) || r.drop[key]
+}
+
+// redactKeys returns the property names of an $unset without the names removed by the allowlist and the drop keys
+func (m *ApiClient) redactKeys(keys []string) []string {
+	r := m.redaction
+	if r == nil || keys == nil {
+		return keys
+	}
+
+	redacted := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if r.removes(key) {
+			continue
+		}
+		redacted = append(redacted, key)
+	}
+	return redacted
+}
+
+// redactIncrements returns the $add values without the properties removed by the allowlist and the drop keys
+// The values are numbers so they are not hashed or scrubbed
+func (m *ApiClient) redactIncrements(add map[string]any) map[string]any {
+	r := m.redaction
+	if r == nil || add == nil {
+		return add
+	}
+
+	redacted := make(map[string]any, len(add))
+	for key, value := range add {
+		if r.removes(key) {
+			continue
+		}
+		redacted[key] = value
+	}
+	return redacted
+}
+
+// redactID returns the distinct id or group id sent for a profile, see redactor.identity
+func (m *ApiClient) redactID(id string) string {
+	return m.redaction.identity(id)
+}
@@ -0,0 +1,275 @@
+package mixpanel
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedactionPolicy(t *testing.T) {
+	policy := RedactionPolicy{
+		DropKeys: []string{"password"},
+		HashKeys: []string{"email"},
+		HashSalt: "salt",
+		Patterns: []*regexp.Regexp{EmailPattern, PhonePattern, IPv4Pattern},
+	}
+	hashed := func(s string) string {
+		hash := sha256.Sum256([]byte("salt" + s))
+		return hex.EncodeToString(hash[:])
+	}
+
+	t.Run("drop, hash and scrub properties", func(t *testing.T) {
+		mp := NewApiClient("token", WithRedaction(policy))
+		properties := map[string]any{
+			"password": "hunter2",
+			"email":    "user@example.com",
+			"comment":  "call me at 555-123-4567 or mail other@example.com",
+			"nested": map[string]any{
+				"password": "hunter2",
+				"from":     "10.0.0.1",
+			},
+			"list":  []any{"a@b.co", 1},
+			"count": 3,
+		}
+
+		redacted := mp.redactProperties(properties)
+		require.Equal(t, map[string]any{
+			"email":   hashed("user@example.com"),
+			"comment": "call me at [REDACTED] or mail [REDACTED]",
+			"nested":  map[string]any{"from": "[REDACTED]"},
+			"list":    []any{"[REDACTED]", 1},
+			"count":   3,
+		}, redacted)
+
+		// the caller's map is left untouched
+		require.Equal(t, "hunter2", properties["password"])
+		require.Equal(t, "hunter2", properties["nested"].(map[string]any)["password"])
+	})
+
+	t.Run("patterns", func(t *testing.T) {
+		for _, s := range []string{"+14155552671", "(415) 555-2671", "+1 415 555 2671", "415.555.2671"} {
+			require.True(t, PhonePattern.MatchString(s), s)
+		}
+		for _, s := range []string{"1700000000000", "4155552671"} {
+			require.False(t, PhonePattern.MatchString(s), s)
+		}
+		require.True(t, IPv4Pattern.MatchString("ip 192.168.1.1"))
+		require.True(t, EmailPattern.MatchString("first.last+tag@example.co.uk"))
+	})
+
+	t.Run("allowlist keeps reserved event properties", func(t *testing.T) {
+		mp := NewApiClient("token", WithRedaction(RedactionPolicy{Allowlist: []string{"plan"}}))
+		event := mp.NewIdentifiedEvent("event", "device-1", "user-1", map[string]any{"plan": "pro", "email": "user@example.com"})
+		event.AddInsertID("insert-id")
+
+		redacted := mp.redactEvents([]*Event{event})
+		require.Equal(t, map[string]any{
+			"plan":             "pro",
+			propertyToken:      "token",
+			propertyDistinctID: "user-1",
+			propertyInsertID:   "insert-id",
+			propertyDeviceID:   "device-1",
+			propertyUserID:     "user-1",
+			propertyMpLib:      goLib,
+			propertyLibVersion: version,
+		}, redacted[0].Properties)
+		require.Contains(t, event.Properties, "email")
+	})
+
+	t.Run("applied to tracked events", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithRedaction(policy))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var tracked []*Event
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&tracked))
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+
+		event := mp.NewEvent("signup", "user-1", map[string]any{"password": "hunter2", "email": "user@example.com"})
+		require.NoError(t, mp.Track(ctx, []*Event{event}))
+		require.NotContains(t, tracked[0].Properties, "password")
+		require.Equal(t, hashed("user@example.com"), tracked[0].Properties["email"])
+		require.Equal(t, "token", tracked[0].Properties[propertyToken])
+	})
+
+	t.Run("applied to people and groups", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithRedaction(RedactionPolicy{DropKeys: []string{"$ip", "secret"}}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var bodies []map[string]any
+		responder := func(req *http.Request) (*http.Response, error) {
+			var payloads []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payloads))
+			bodies = append(bodies, payloads...)
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		}
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, peopleSetURL), responder)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, peopleSetOnceURL), responder)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, groupSetUrl), responder)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, groupsSetOnceUrl), responder)
+
+		people := NewPeopleProperties("user-1", map[string]any{"secret": "x", "name": "user", "$ip": "10.0.0.1"})
+		require.NoError(t, mp.PeopleSet(ctx, []*PeopleProperties{people}))
+		require.NoError(t, mp.PeopleSetOnce(ctx, []*PeopleProperties{people}))
+		require.NoError(t, mp.GroupSet(ctx, "company", "1", map[string]any{"secret": "x", "name": "acme"}))
+		require.NoError(t, mp.GroupSetOnce(ctx, "company", "1", map[string]any{"secret": "x", "name": "acme"}))
+
+		require.Len(t, bodies, 4)
+		require.Equal(t, map[string]any{"name": "user"}, bodies[0]["$set"])
+		require.Equal(t, "0", bodies[0]["$ip"])
+		require.Equal(t, map[string]any{"name": "user"}, bodies[1]["$set_once"])
+		require.Equal(t, map[string]any{"name": "acme"}, bodies[2]["$set"])
+		require.Equal(t, map[string]any{"name": "acme"}, bodies[3]["$set_once"])
+		require.Contains(t, people.Properties, "secret")
+	})
+
+	t.Run("applied to every profile operation", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithRedaction(RedactionPolicy{
+			Allowlist: []string{"emails", "tags", "logins"},
+			Patterns:  []*regexp.Regexp{EmailPattern},
+		}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var bodies []map[string]any
+		responder := func(req *http.Request) (*http.Response, error) {
+			var payloads []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payloads))
+			bodies = append(bodies, payloads...)
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		}
+		for _, u := range []string{
+			peopleUnionToListUrl, peopleAppendToListUrl, peopleRemoveFromListUrl, peopleDeletePropertyUrl, peopleIncrementUrl,
+			groupsUnionListPropertyUrl, groupsRemoveFromListPropertyUrl, groupsDeletePropertyUrl, peopleBatchURL, groupsBatchURL,
+		} {
+			httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, u), responder)
+		}
+
+		list := map[string]any{"emails": []string{"bob@example.com"}, "secret": "x"}
+		require.NoError(t, mp.PeopleUnionProperty(ctx, "user-1", list))
+		require.NoError(t, mp.PeopleAppendListProperty(ctx, "user-1", list))
+		require.NoError(t, mp.PeopleRemoveListProperty(ctx, "user-1", list))
+		require.NoError(t, mp.PeopleDeleteProperty(ctx, "user-1", []string{"tags", "secret"}))
+		require.NoError(t, mp.PeopleAdd(ctx, "user-1", map[string]any{"logins": 1, "secret": 2}))
+		require.NoError(t, mp.GroupUnionListProperty(ctx, "company", "1", list))
+		require.NoError(t, mp.GroupRemoveListProperty(ctx, "company", "1", list))
+		require.NoError(t, mp.GroupDeleteProperty(ctx, "company", "1", []string{"tags", "secret"}))
+		require.NoError(t, mp.NewPeopleBatch().Union("user-1", list).Append("user-1", list).Remove("user-1", list).Unset("user-1", []string{"secret"}).Flush(ctx))
+		require.NoError(t, mp.NewGroupBatch().Union("company", "1", list).Remove("company", "1", list).Flush(ctx))
+
+		redacted := map[string]any{"emails": []any{"[REDACTED]"}}
+		for i, operation := range []string{"$union", "$append", "$remove"} {
+			require.Equal(t, redacted, bodies[i][operation])
+		}
+		require.Equal(t, []any{"tags"}, bodies[3]["$unset"])
+		require.Equal(t, map[string]any{"logins": float64(1)}, bodies[4]["$add"])
+		require.Equal(t, redacted, bodies[5]["$union"])
+		require.Equal(t, redacted, bodies[6]["$remove"])
+		require.Equal(t, []any{"tags"}, bodies[7]["$unset"])
+		for i, operation := range []string{"$union", "$append", "$remove"} {
+			require.Equal(t, redacted, bodies[8+i][operation])
+		}
+		require.Equal(t, []any{}, bodies[11]["$unset"])
+		require.Equal(t, redacted, bodies[12]["$union"])
+		require.Equal(t, redacted, bodies[13]["$remove"])
+		require.Contains(t, list, "secret")
+	})
+
+	t.Run("identity ids are hashed the same in events and profiles", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithRedaction(RedactionPolicy{HashSalt: "salt", Patterns: []*regexp.Regexp{EmailPattern}}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var tracked []*Event
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&tracked))
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+		var bodies []map[string]any
+		responder := func(req *http.Request) (*http.Response, error) {
+			var payloads []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&payloads))
+			bodies = append(bodies, payloads...)
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		}
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, peopleSetURL), responder)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, peopleDeleteProfileUrl), responder)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, groupSetUrl), responder)
+
+		alice := hashed("alice@example.com")
+		events := []*Event{
+			mp.NewIdentifiedEvent("login", "device-1", "alice@example.com", nil),
+			mp.NewEvent("login", "bob@example.com", nil),
+			mp.NewEvent("login", "user-1", nil),
+		}
+		require.NoError(t, mp.Track(ctx, events))
+		require.Equal(t, alice, tracked[0].Properties[propertyDistinctID])
+		require.Equal(t, alice, tracked[0].Properties[propertyUserID])
+		require.Equal(t, "device-1", tracked[0].Properties[propertyDeviceID])
+		require.Equal(t, hashed("bob@example.com"), tracked[1].Properties[propertyDistinctID])
+		require.Equal(t, "user-1", tracked[2].Properties[propertyDistinctID])
+
+		require.NoError(t, mp.PeopleSet(ctx, []*PeopleProperties{NewPeopleProperties("alice@example.com", map[string]any{"plan": "pro"})}))
+		require.NoError(t, mp.PeopleDeleteProfile(ctx, "alice@example.com", false))
+		require.NoError(t, mp.GroupSet(ctx, "company", "ceo@acme.com", map[string]any{"plan": "pro"}))
+		require.Len(t, bodies, 3)
+		require.Equal(t, alice, bodies[0]["$distinct_id"])
+		require.Equal(t, alice, bodies[1]["$distinct_id"])
+		require.Equal(t, hashed("ceo@acme.com"), bodies[2]["$group_id"])
+	})
+
+	t.Run("anonymous distinct ids keep the device prefix", func(t *testing.T) {
+		mp := NewApiClient("token", WithRedaction(RedactionPolicy{HashKeys: []string{propertyDistinctID}, HashSalt: "salt"}))
+		redacted := mp.redactEvents([]*Event{mp.NewIdentifiedEvent("page view", "dev1", "", nil)})
+		require.Equal(t, "$device:"+hashed("dev1"), redacted[0].Properties[propertyDistinctID])
+		require.Equal(t, hashed("dev1"), redacted[0].Properties[propertyDeviceID])
+	})
+
+	t.Run("hash keys hash every id", func(t *testing.T) {
+		mp := NewApiClient("token", WithRedaction(RedactionPolicy{HashKeys: []string{propertyDistinctID}, HashSalt: "salt"}))
+		redacted := mp.redactEvents([]*Event{mp.NewEvent("login", "user-1", nil)})
+		require.Equal(t, hashed("user-1"), redacted[0].Properties[propertyDistinctID])
+		require.Equal(t, hashed("user-1"), mp.redactID("user-1"))
+	})
+
+	t.Run("applied to the debug dump", func(t *testing.T) {
+		ctx := context.Background()
+		var dump bytes.Buffer
+		mp := NewApiClient("token", DebugHttpCalls(&dump), WithRedaction(RedactionPolicy{Patterns: []*regexp.Regexp{EmailPattern}}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			body, err := io.ReadAll(req.Body)
+			require.NoError(t, err)
+			require.Contains(t, string(body), "user@example.com")
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+
+		// event names are not redacted so the email only reaches the dump through the name
+		require.NoError(t, mp.Track(ctx, []*Event{mp.NewEvent("mailed user@example.com", "user-1", nil)}))
+		require.NotContains(t, dump.String(), "user@example.com")
+		require.Contains(t, dump.String(), "[REDACTED]")
+	})
+}
@@ -0,0 +1,198 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	retryAfterHeader = "Retry-After"
+
+	defaultRetryMaxAttempts = 5
+	defaultRetryBaseDelay   = 500 * time.Millisecond
+	defaultRetryMaxDelay    = 30 * time.Second
+	defaultRetryJitter      = 0.2
+)
+
+// EndpointFamily groups the apis that share the same retry behavior
+type EndpointFamily uint
+
+const (
+	EndpointTrack EndpointFamily = 1 << iota
+	EndpointImport
+	EndpointPeople
+	// EndpointPeopleIncrement is the people $add and $append apis, retrying them can increment a property or append the values twice
+	EndpointPeopleIncrement
+	EndpointGroups
+	EndpointIdentity
+	EndpointExport
+	// EndpointQuery is the engage query api that reads profiles
+	EndpointQuery
+
+	// EndpointIdempotent are all the endpoints that are safe to retry
+	EndpointIdempotent = EndpointTrack | EndpointImport | EndpointPeople | EndpointGroups | EndpointIdentity | EndpointExport | EndpointQuery
+)
+
+// RetryOn are the failures that are retried
+type RetryOn uint
+
+const (
+	// RetryOnRateLimit retries http 429 responses
+	RetryOnRateLimit RetryOn = 1 << iota
+	// RetryOnServerError retries http 5xx responses
+	RetryOnServerError
+	// RetryOnNetworkError retries requests that failed without a response
+	RetryOnNetworkError
+
+	RetryOnAll = RetryOnRateLimit | RetryOnServerError | RetryOnNetworkError
+)
+
+// RetryPolicy configures how failed requests are retried with exponential backoff
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first request
+	MaxAttempts int
+	// BaseDelay is the delay before the first retry, it doubles on every attempt
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff delay, a Retry-After header sent by the server is always honored
+	MaxDelay time.Duration
+	// Jitter is the fraction of the delay that is randomized, between 0 and 1
+	Jitter float64
+
+	RetryOn   RetryOn
+	Endpoints EndpointFamily
+}
+
+// DefaultRetryPolicy retries every failure on the idempotent endpoints
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: defaultRetryMaxAttempts,
+		BaseDelay:   defaultRetryBaseDelay,
+		MaxDelay:    defaultRetryMaxDelay,
+		Jitter:      defaultRetryJitter,
+		RetryOn:     RetryOnAll,
+		Endpoints:   EndpointIdempotent,
+	}
+}
+
+// WithRetryPolicy retries failed requests according to the policy
+func WithRetryPolicy(policy RetryPolicy) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.retryPolicy = &policy
+	}
+}
+
+func (p *RetryPolicy) appliesTo(endpoint EndpointFamily) bool {
+	return p != nil && p.MaxAttempts > 1 && p.Endpoints&endpoint != 0
+}
+
+func (p *RetryPolicy) shouldRetry(ctx context.Context, response *http.Response, err error) bool {
+	if err != nil {
+		if ctx.Err() != nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || isBodyEncodingError(err) {
+			return false
+		}
+		return p.RetryOn&RetryOnNetworkError != 0
+	}
+
+	switch {
+	case response.StatusCode == http.StatusTooManyRequests:
+		return p.RetryOn&RetryOnRateLimit != 0
+	case response.StatusCode >= http.StatusInternalServerError:
+		return p.RetryOn&RetryOnServerError != 0
+	default:
+		return false
+	}
+}
+
+// delay returns how long to wait before the next attempt
+func (p *RetryPolicy) delay(attempt int, response *http.Response) time.Duration {
+	if response != nil {
+		if retryAfter, ok := parseRetryAfter(response.Header.Get(retryAfterHeader)); ok {
+			return retryAfter
+		}
+	}
+
+	delay := p.BaseDelay
+	for i := 1; i < attempt && (p.MaxDelay <= 0 || delay < p.MaxDelay); i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		jitter := p.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+		delay -= time.Duration(float64(delay) * jitter * rand.Float64())
+	}
+	return delay
+}
+
+// parseRetryAfter parses the Retry-After header which is either seconds or a http date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+
+	return 0, false
+}
+
+func (m *ApiClient) doWithRetry(ctx context.Context, request *http.Request) (*http.Response, error) {
+	policy := m.retryPolicy
+	for attempt := 1; ; attempt++ {
+		response, err := m.client.Do(request)
+		if attempt >= policy.MaxAttempts || !policy.shouldRetry(ctx, response, err) {
+			return response, err
+		}
+
+		// the body can only be sent again if it can be recreated
+		hasBody := request.Body != nil && request.Body != http.NoBody
+		if hasBody && request.GetBody == nil {
+			return response, err
+		}
+
+		delay := policy.delay(attempt, response)
+		if response != nil {
+			_, _ = io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		next := request.Clone(ctx)
+		if hasBody {
+			next.Body, err = request.GetBody()
+			if err != nil {
+				return nil, err
+			}
+		}
+		request = next
+	}
+}
@@ -0,0 +1,198 @@
+package mixpanel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func testRetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.BaseDelay = time.Millisecond
+	policy.MaxDelay = 5 * time.Millisecond
+	return policy
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	delay, ok := parseRetryAfter("3")
+	require.True(t, ok)
+	require.Equal(t, 3*time.Second, delay)
+
+	delay, ok = parseRetryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat))
+	require.True(t, ok)
+	require.Greater(t, delay, 59*time.Minute)
+
+	_, ok = parseRetryAfter("")
+	require.False(t, ok)
+
+	_, ok = parseRetryAfter("soon")
+	require.False(t, ok)
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := RetryPolicy{
+		BaseDelay: 100 * time.Millisecond,
+		MaxDelay:  time.Second,
+	}
+	require.Equal(t, 100*time.Millisecond, policy.delay(1, nil))
+	require.Equal(t, 200*time.Millisecond, policy.delay(2, nil))
+	require.Equal(t, 800*time.Millisecond, policy.delay(4, nil))
+	require.Equal(t, time.Second, policy.delay(10, nil))
+
+	response := &http.Response{Header: http.Header{}}
+	response.Header.Set(retryAfterHeader, "7")
+	require.Equal(t, 7*time.Second, policy.delay(1, response))
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := policy.delay(2, nil)
+		require.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		require.LessOrEqual(t, delay, 200*time.Millisecond)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	// responds with the given status codes in order and then with success
+	setupFlakyEndpoint := func(t *testing.T, url string, statusCodes []int, success string) {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		call := 0
+		httpmock.RegisterResponder(http.MethodPost, url, func(req *http.Request) (*http.Response, error) {
+			defer func() { call++ }()
+			if call < len(statusCodes) {
+				response := httpmock.NewStringResponse(statusCodes[call], `{"code": 0, "error": "failed", "status": 0}`)
+				response.Header.Set(retryAfterHeader, "0")
+				return response, nil
+			}
+			return httpmock.NewStringResponse(http.StatusOK, success), nil
+		})
+	}
+
+	t.Run("track retries server errors", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithRetryPolicy(testRetryPolicy()))
+		setupFlakyEndpoint(t, mp.apiEndpoint+trackURL, []int{http.StatusServiceUnavailable, http.StatusBadGateway}, `{"error": "", "status": 1}`)
+
+		require.NoError(t, mp.Track(ctx, []*Event{mp.NewEvent("event", EmptyDistinctID, nil)}))
+		require.Equal(t, 3, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("import retries rate limits and resends the body", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithRetryPolicy(testRetryPolicy()))
+		events := []*Event{mp.NewEvent("event", EmptyDistinctID, nil)}
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		call := 0
+		httpmock.RegisterResponder(http.MethodPost, mp.apiEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			call++
+			require.Equal(t, events, decodeImportRequest(t, req))
+			if call == 1 {
+				return httpmock.NewStringResponse(http.StatusTooManyRequests, `{"code": 429, "error": "rate limited", "status": 0}`), nil
+			}
+			return httpmock.NewStringResponse(http.StatusOK, `{"code": 200,"num_records_imported": 1,"status": 1}`), nil
+		})
+
+		success, err := mp.Import(ctx, events, ImportOptionsRecommend)
+		require.NoError(t, err)
+		require.Equal(t, 1, success.NumRecordsImported)
+		require.Equal(t, 2, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		ctx := context.Background()
+		policy := testRetryPolicy()
+		policy.MaxAttempts = 3
+		mp := NewApiClient("token", WithRetryPolicy(policy))
+		setupFlakyEndpoint(t, mp.apiEndpoint+importURL, []int{429, 429, 429, 429}, "")
+
+		_, err := mp.Import(ctx, []*Event{mp.NewEvent("event", EmptyDistinctID, nil)}, ImportOptionsRecommend)
+		rateLimitError := ImportRateLimitError{}
+		require.ErrorAs(t, err, &rateLimitError)
+		require.Equal(t, 3, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("people increment is not retried by default", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithRetryPolicy(testRetryPolicy()))
+		setupFlakyEndpoint(t, mp.apiEndpoint+peopleIncrementUrl, []int{http.StatusInternalServerError}, "1")
+
+		require.Error(t, mp.PeopleIncrement(ctx, "some-id", map[string]int{"count": 1}))
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("people append is not retried by default", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithRetryPolicy(testRetryPolicy()))
+		setupFlakyEndpoint(t, mp.apiEndpoint+peopleAppendToListUrl, []int{http.StatusInternalServerError}, "1")
+
+		require.Error(t, mp.PeopleAppendListProperty(ctx, "some-id", map[string]any{"history": "x"}))
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("people increment is retried when opted in", func(t *testing.T) {
+		ctx := context.Background()
+		policy := testRetryPolicy()
+		policy.Endpoints |= EndpointPeopleIncrement
+		mp := NewApiClient("token", WithRetryPolicy(policy))
+		setupFlakyEndpoint(t, mp.apiEndpoint+peopleIncrementUrl, []int{http.StatusInternalServerError}, "1")
+
+		require.NoError(t, mp.PeopleIncrement(ctx, "some-id", map[string]int{"count": 1}))
+		require.Equal(t, 2, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("only retries the configured failures", func(t *testing.T) {
+		ctx := context.Background()
+		policy := testRetryPolicy()
+		policy.RetryOn = RetryOnRateLimit
+		mp := NewApiClient("token", WithRetryPolicy(policy))
+		setupFlakyEndpoint(t, mp.apiEndpoint+groupSetUrl, []int{http.StatusInternalServerError}, "1")
+
+		require.Error(t, mp.GroupSet(ctx, "company", "id", map[string]any{"plan": "free"}))
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("retries network errors", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithRetryPolicy(testRetryPolicy()))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		call := 0
+		httpmock.RegisterResponder(http.MethodPost, mp.apiEndpoint+peopleSetURL, func(req *http.Request) (*http.Response, error) {
+			call++
+			if call == 1 {
+				return nil, errors.New("connection reset")
+			}
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		})
+
+		require.NoError(t, mp.PeopleSet(ctx, []*PeopleProperties{NewPeopleProperties("some-id", nil)}))
+		require.Equal(t, 2, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("stops waiting when the context is canceled", func(t *testing.T) {
+		policy := testRetryPolicy()
+		policy.BaseDelay = time.Hour
+		policy.MaxDelay = time.Hour
+		mp := NewApiClient("token", WithRetryPolicy(policy))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, trackURL), httpmock.NewStringResponder(http.StatusServiceUnavailable, ""))
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		err := mp.Track(ctx, []*Event{mp.NewEvent("event", EmptyDistinctID, nil)})
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+		require.Equal(t, 1, httpmock.GetTotalCallCount())
+	})
+}
@@ -0,0 +1,98 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+)
+
+// SampleRateProperty is added to the events kept by a sampling rule with the rate of the rule
+// Weight the event by 1/rate to estimate the unsampled totals
+const SampleRateProperty = "sample_rate"
+
+// SamplingRule keeps a fraction of the events it matches
+type SamplingRule struct {
+	// EventName matches the events with the name, empty matches every event
+	EventName string
+	// Match is an optional predicate, the rule applies when both EventName and Match match
+	Match func(event *Event) bool
+	// Rate is the fraction of the events kept, between 0 and 1
+	Rate float64
+}
+
+func (r SamplingRule) matches(event *Event) bool {
+	if event == nil {
+		return false
+	}
+	if r.EventName != "" && r.EventName != event.Name {
+		return false
+	}
+	return r.Match == nil || r.Match(event)
+}
+
+// NewSamplingInterceptor returns an interceptor that drops the events not selected by the first matching rule
+// The decision is made on the distinct_id so a user is either kept or dropped for all the sampled events
+// Anonymous events without a distinct_id are sampled on their $device_id, then on their $insert_id, else at random
+func NewSamplingInterceptor(rules ...SamplingRule) EventInterceptor {
+	rules = append([]SamplingRule(nil), rules...)
+	return func(ctx context.Context, event *Event) (*Event, error) {
+		// events without properties are left to the validation
+		if event == nil || event.Properties == nil {
+			return event, nil
+		}
+
+		for _, rule := range rules {
+			if !rule.matches(event) {
+				continue
+			}
+
+			rate := math.Max(0, math.Min(1, rule.Rate))
+			if !sampledEvent(event, rate) {
+				return nil, nil
+			}
+			event.Properties[SampleRateProperty] = rate
+			return event, nil
+		}
+		return event, nil
+	}
+}
+
+// WithSampling adds a sampling interceptor with the rules to the client
+func WithSampling(rules ...SamplingRule) Options {
+	return WithEventInterceptors(NewSamplingInterceptor(rules...))
+}
+
+// samplingKeys are the properties the sampling decision is made on, in order
+var samplingKeys = []string{propertyDistinctID, propertyDeviceID, propertyInsertID}
+
+func sampledEvent(event *Event, rate float64) bool {
+	for _, key := range samplingKeys {
+		if value, ok := event.Properties[key]; ok && value != nil && value != "" {
+			return sampled(value, rate)
+		}
+	}
+	return rand.Float64() < rate
+}
+
+// sampled maps the distinct_id to [0, 1) and keeps the ones below rate
+func sampled(distinctID any, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+
+	hash := fnv.New64a()
+	_, _ = fmt.Fprint(hash, distinctID)
+	return float64(mix64(hash.Sum64()))/float64(math.MaxUint64) < rate
+}
+
+// mix64 is the murmur3 finalizer, fnv alone leaves the high bits of similar ids like user-1 and user-2 close together
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
@@ -0,0 +1,151 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSampling(t *testing.T) {
+	newEvents := func(mp *ApiClient, name string, count int) []*Event {
+		events := make([]*Event, count)
+		for i := range events {
+			events[i] = mp.NewEvent(name, fmt.Sprintf("user-%d", i), nil)
+		}
+		return events
+	}
+
+	t.Run("keeps roughly the rate of matching events", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		interceptor := NewSamplingInterceptor(SamplingRule{EventName: "api_call", Rate: 0.1})
+
+		kept := 0
+		for _, event := range newEvents(mp, "api_call", 10_000) {
+			event, err := interceptor(ctx, event)
+			require.NoError(t, err)
+			if event != nil {
+				kept++
+				require.Equal(t, 0.1, event.Properties[SampleRateProperty])
+			}
+		}
+		require.InDelta(t, 1_000, kept, 150)
+	})
+
+	t.Run("deterministic on distinct_id", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		interceptor := NewSamplingInterceptor(SamplingRule{Rate: 0.5})
+
+		for i := 0; i < 100; i++ {
+			distinctID := fmt.Sprintf("user-%d", i)
+			first, err := interceptor(ctx, mp.NewEvent("page_view", distinctID, nil))
+			require.NoError(t, err)
+			second, err := interceptor(ctx, mp.NewEvent("checkout", distinctID, nil))
+			require.NoError(t, err)
+			require.Equal(t, first == nil, second == nil)
+		}
+	})
+
+	t.Run("anonymous events fall back to the device id and insert id", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		interceptor := NewSamplingInterceptor(SamplingRule{Rate: 0.5})
+
+		byDevice, byInsertID, random := 0, 0, 0
+		for i := 0; i < 2_000; i++ {
+			device := mp.NewIdentifiedEvent("page_view", fmt.Sprintf("device-%d", i), "", nil)
+			device.Properties[propertyDistinctID] = EmptyDistinctID
+			kept, err := interceptor(ctx, device)
+			require.NoError(t, err)
+			require.Equal(t, sampled(fmt.Sprintf("device-%d", i), 0.5), kept != nil)
+			if kept != nil {
+				byDevice++
+			}
+
+			inserted := mp.NewEvent("page_view", EmptyDistinctID, nil)
+			inserted.AddInsertID(fmt.Sprintf("insert-%d", i))
+			kept, err = interceptor(ctx, inserted)
+			require.NoError(t, err)
+			require.Equal(t, sampled(fmt.Sprintf("insert-%d", i), 0.5), kept != nil)
+			if kept != nil {
+				byInsertID++
+			}
+
+			kept, err = interceptor(ctx, mp.NewEvent("page_view", EmptyDistinctID, nil))
+			require.NoError(t, err)
+			if kept != nil {
+				random++
+			}
+		}
+		require.InDelta(t, 1_000, byDevice, 150)
+		require.InDelta(t, 1_000, byInsertID, 150)
+		require.InDelta(t, 1_000, random, 150)
+	})
+
+	t.Run("events without properties are not sampled", func(t *testing.T) {
+		ctx := context.Background()
+		interceptor := NewSamplingInterceptor(SamplingRule{Rate: 1})
+
+		event := &Event{Name: "page_view"}
+		kept, err := interceptor(ctx, event)
+		require.NoError(t, err)
+		require.Same(t, event, kept)
+		require.Nil(t, kept.Properties)
+
+		kept, err = interceptor(ctx, nil)
+		require.NoError(t, err)
+		require.Nil(t, kept)
+		require.False(t, SamplingRule{}.matches(nil))
+	})
+
+	t.Run("lower rates keep a subset of the users", func(t *testing.T) {
+		for i := 0; i < 1_000; i++ {
+			distinctID := fmt.Sprintf("user-%d", i)
+			if sampled(distinctID, 0.1) {
+				require.True(t, sampled(distinctID, 0.5))
+			}
+		}
+	})
+
+	t.Run("first matching rule wins", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token")
+		interceptor := NewSamplingInterceptor(
+			SamplingRule{
+				Match: func(event *Event) bool { return event.Properties["internal"] == true },
+				Rate:  0,
+			},
+			SamplingRule{EventName: "heartbeat", Rate: 1},
+		)
+
+		event, err := interceptor(ctx, mp.NewEvent("heartbeat", "user-1", map[string]any{"internal": true}))
+		require.NoError(t, err)
+		require.Nil(t, event)
+
+		event, err = interceptor(ctx, mp.NewEvent("heartbeat", "user-1", nil))
+		require.NoError(t, err)
+		require.Equal(t, 1.0, event.Properties[SampleRateProperty])
+
+		event, err = interceptor(ctx, mp.NewEvent("signup", "user-1", nil))
+		require.NoError(t, err)
+		require.NotContains(t, event.Properties, SampleRateProperty)
+	})
+
+	t.Run("applied to track", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithSampling(SamplingRule{EventName: "heartbeat", Rate: 0}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, trackURL), httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		require.NoError(t, mp.Track(ctx, newEvents(mp, "heartbeat", 10)))
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+		require.Equal(t, InterceptorStats{Dropped: 10}, mp.InterceptorStats())
+	})
+}
@@ -0,0 +1,524 @@
+package mixpanel
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	spoolSegmentExtension = ".ndjson"
+
+	spoolTrackEndpoint  = "track"
+	spoolImportEndpoint = "import"
+
+	defaultSpoolMaxBytes       = 256 * 1024 * 1024
+	defaultSpoolSegmentBytes   = 8 * 1024 * 1024
+	defaultSpoolReplayInterval = 30 * time.Second
+)
+
+var (
+	ErrSpoolFull = errors.New("spool is full")
+)
+
+// SpoolSyncPolicy controls when spooled events are fsynced to disk
+type SpoolSyncPolicy int
+
+const (
+	// SpoolSyncAlways fsyncs the segment after every write
+	SpoolSyncAlways SpoolSyncPolicy = iota
+	// SpoolSyncOnRotate fsyncs the segment when it is rotated or the client is closed
+	SpoolSyncOnRotate
+	// SpoolSyncNever leaves flushing to the operating system
+	SpoolSyncNever
+)
+
+// SpoolConfig configures the on-disk spool used when the api can't be reached
+type SpoolConfig struct {
+	// Dir is the directory the segments are written to, it is created if missing
+	Dir string
+	// MaxBytes caps the total size of the spool, events are dropped once it is reached
+	MaxBytes int64
+	// SegmentBytes is the size a segment can grow to before a new one is started
+	SegmentBytes int64
+	Sync         SpoolSyncPolicy
+	// ReplayInterval is how often the spool tries to replay the spooled events
+	ReplayInterval time.Duration
+
+	// OnError is called when spooled events can't be replayed or a corrupted segment is found
+	OnError func(err error)
+}
+
+// DefaultSpoolConfig returns the recommended spool configuration
+func DefaultSpoolConfig(dir string) SpoolConfig {
+	return SpoolConfig{
+		Dir:            dir,
+		MaxBytes:       defaultSpoolMaxBytes,
+		SegmentBytes:   defaultSpoolSegmentBytes,
+		Sync:           SpoolSyncAlways,
+		ReplayInterval: defaultSpoolReplayInterval,
+	}
+}
+
+// WithSpool writes the events of Track and Import calls that failed because the api could not be reached
+// to disk and replays them in order once the api recovers
+// Events without an $insert_id get one so replays are deduplicated by mixpanel
+func WithSpool(config SpoolConfig) Options {
+	return func(mixpanel *ApiClient) {
+		if config.MaxBytes <= 0 {
+			config.MaxBytes = defaultSpoolMaxBytes
+		}
+		if config.SegmentBytes <= 0 {
+			config.SegmentBytes = defaultSpoolSegmentBytes
+		}
+		if config.ReplayInterval <= 0 {
+			config.ReplayInterval = defaultSpoolReplayInterval
+		}
+
+		mixpanel.spool = &spool{
+			client: mixpanel,
+			config: config,
+			notify: make(chan struct{}, 1),
+			stop:   make(chan struct{}),
+			done:   make(chan struct{}),
+		}
+	}
+}
+
+// SpooledError is returned when the events could not be sent and were written to the spool instead
+type SpooledError struct {
+	Err error
+}
+
+func (e SpooledError) Error() string {
+	return fmt.Sprintf("events spooled for replay: %s", e.Err)
+}
+
+func (e SpooledError) Unwrap() error {
+	return e.Err
+}
+
+// SpoolCorruptionError reports a line of a segment that could not be decoded and was skipped
+type SpoolCorruptionError struct {
+	Segment string
+	Line    int
+	Err     error
+}
+
+func (e SpoolCorruptionError) Error() string {
+	return fmt.Sprintf("corrupted spool segment %s line %d: %s", e.Segment, e.Line, e.Err)
+}
+
+func (e SpoolCorruptionError) Unwrap() error {
+	return e.Err
+}
+
+type spoolRecord struct {
+	Endpoint string `json:"endpoint"`
+	Strict   bool   `json:"strict,omitempty"`
+	Event    *Event `json:"event"`
+}
+
+type spoolSegment struct {
+	seq  uint64
+	size int64
+}
+
+type spool struct {
+	client *ApiClient
+	config SpoolConfig
+
+	mu         sync.Mutex
+	err        error
+	segments   []spoolSegment
+	totalBytes int64
+	nextSeq    uint64
+	active     *os.File
+
+	replayMu  sync.Mutex
+	notify    chan struct{}
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// isSpoolable reports if the error means the api could not be reached
+func isSpoolable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || isBodyEncodingError(err) {
+		return false
+	}
+
+	var httpError HttpError
+	if errors.As(err, &httpError) {
+		return httpError.Status == http.StatusTooManyRequests || httpError.Status >= http.StatusInternalServerError
+	}
+
+	var rateLimitError ImportRateLimitError
+	if errors.As(err, &rateLimitError) {
+		return true
+	}
+
+	var urlError *url.Error
+	return errors.As(err, &urlError)
+}
+
+func (s *spool) segmentPath(seq uint64) string {
+	return filepath.Join(s.config.Dir, fmt.Sprintf("%020d%s", seq, spoolSegmentExtension))
+}
+
+func (s *spool) start() {
+	if err := s.recover(); err != nil {
+		s.err = err
+		s.reportError(err)
+	}
+	go s.run()
+}
+
+// recover loads the segments left by a previous process and repairs a torn write at the end of the last one
+func (s *spool) recover() error {
+	if err := os.MkdirAll(s.config.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create spool directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(s.config.Dir)
+	if err != nil {
+		return fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, spoolSegmentExtension) {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, spoolSegmentExtension), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return fmt.Errorf("failed to stat spool segment: %w", err)
+		}
+		s.segments = append(s.segments, spoolSegment{seq: seq, size: info.Size()})
+	}
+
+	sort.Slice(s.segments, func(i, j int) bool {
+		return s.segments[i].seq < s.segments[j].seq
+	})
+
+	if len(s.segments) > 0 {
+		last := &s.segments[len(s.segments)-1]
+		size, err := truncateTornWrite(s.segmentPath(last.seq))
+		if err != nil {
+			return err
+		}
+		last.size = size
+		s.nextSeq = last.seq + 1
+	}
+
+	for _, segment := range s.segments {
+		s.totalBytes += segment.size
+	}
+	return nil
+}
+
+// truncateTornWrite removes a partially written line at the end of the segment
+func truncateTornWrite(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read spool segment: %w", err)
+	}
+	if len(data) == 0 || data[len(data)-1] == '\n' {
+		return int64(len(data)), nil
+	}
+
+	size := int64(bytes.LastIndexByte(data, '\n') + 1)
+	if err := os.Truncate(path, size); err != nil {
+		return 0, fmt.Errorf("failed to truncate spool segment: %w", err)
+	}
+	return size, nil
+}
+
+func (s *spool) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.config.ReplayInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.notify:
+		}
+
+		if err := s.replay(context.Background()); err != nil {
+			s.reportError(err)
+		}
+	}
+}
+
+func (s *spool) reportError(err error) {
+	if s.config.OnError != nil {
+		s.config.OnError(err)
+	}
+}
+
+// wake triggers a replay if there are spooled events
+func (s *spool) wake() {
+	s.mu.Lock()
+	pending := len(s.segments) > 0
+	s.mu.Unlock()
+
+	if !pending {
+		return
+	}
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (s *spool) append(endpoint string, strict bool, events []*Event) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, event := range events {
+		if event.Properties == nil {
+			event.Properties = make(map[string]any)
+		}
+		if _, ok := event.Properties[propertyInsertID]; !ok {
+			insertID, err := newRandomInsertID()
+			if err != nil {
+				return err
+			}
+			event.AddInsertID(insertID)
+		}
+		if err := encoder.Encode(spoolRecord{Endpoint: endpoint, Strict: strict, Event: event}); err != nil {
+			return fmt.Errorf("failed to encode spool record: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return s.err
+	}
+	if s.totalBytes+int64(buf.Len()) > s.config.MaxBytes {
+		return ErrSpoolFull
+	}
+
+	if s.active == nil || (s.segments[len(s.segments)-1].size > 0 && s.segments[len(s.segments)-1].size+int64(buf.Len()) > s.config.SegmentBytes) {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.active.Write(buf.Bytes())
+	s.segments[len(s.segments)-1].size += int64(n)
+	s.totalBytes += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write spool segment: %w", err)
+	}
+
+	if s.config.Sync == SpoolSyncAlways {
+		if err := s.active.Sync(); err != nil {
+			return fmt.Errorf("failed to sync spool segment: %w", err)
+		}
+	}
+	return nil
+}
+
+// rotate seals the active segment and starts a new one
+func (s *spool) rotate() error {
+	if err := s.closeActive(); err != nil {
+		return err
+	}
+
+	seq := s.nextSeq
+	file, err := os.OpenFile(s.segmentPath(seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND|os.O_EXCL, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %w", err)
+	}
+
+	s.nextSeq++
+	s.active = file
+	s.segments = append(s.segments, spoolSegment{seq: seq})
+	return nil
+}
+
+func (s *spool) closeActive() error {
+	if s.active == nil {
+		return nil
+	}
+
+	active := s.active
+	s.active = nil
+	if s.config.Sync != SpoolSyncNever {
+		if err := active.Sync(); err != nil {
+			active.Close()
+			return fmt.Errorf("failed to sync spool segment: %w", err)
+		}
+	}
+	return active.Close()
+}
+
+// replay sends the spooled segments from oldest to newest
+// A segment is only removed once all of its events were sent, so a failed replay resends them later
+func (s *spool) replay(ctx context.Context) error {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	for {
+		s.mu.Lock()
+		if len(s.segments) == 0 {
+			s.mu.Unlock()
+			return nil
+		}
+		segment := s.segments[0]
+		if len(s.segments) == 1 && s.active != nil {
+			if err := s.closeActive(); err != nil {
+				s.mu.Unlock()
+				return err
+			}
+		}
+		s.mu.Unlock()
+
+		path := s.segmentPath(segment.seq)
+		records, err := s.readSegment(path)
+		if err != nil {
+			return err
+		}
+		if err := s.send(ctx, records); err != nil {
+			return err
+		}
+
+		s.mu.Lock()
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			s.mu.Unlock()
+			return fmt.Errorf("failed to remove spool segment: %w", err)
+		}
+		s.segments = s.segments[1:]
+		s.totalBytes -= segment.size
+		s.mu.Unlock()
+	}
+}
+
+// readSegment decodes the records of a segment, corrupted lines are reported and skipped
+func (s *spool) readSegment(path string) ([]spoolRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spool segment: %w", err)
+	}
+	defer file.Close()
+
+	var records []spoolRecord
+	reader := bufio.NewReader(file)
+	for line := 1; ; line++ {
+		data, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			if len(data) > 0 {
+				s.reportError(SpoolCorruptionError{Segment: path, Line: line, Err: io.ErrUnexpectedEOF})
+			}
+			return records, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read spool segment: %w", err)
+		}
+
+		var record spoolRecord
+		if err := json.Unmarshal(data, &record); err != nil {
+			s.reportError(SpoolCorruptionError{Segment: path, Line: line, Err: err})
+			continue
+		}
+		if record.Event == nil || (record.Endpoint != spoolTrackEndpoint && record.Endpoint != spoolImportEndpoint) {
+			s.reportError(SpoolCorruptionError{Segment: path, Line: line, Err: errors.New("invalid spool record")})
+			continue
+		}
+		records = append(records, record)
+	}
+}
+
+// send replays consecutive records of the same endpoint together
+// Errors caused by the events themselves are reported and the events are dropped
+func (s *spool) send(ctx context.Context, records []spoolRecord) error {
+	for start := 0; start < len(records); {
+		end := start + 1
+		for end < len(records) && end-start < MaxImportEvents &&
+			records[end].Endpoint == records[start].Endpoint && records[end].Strict == records[start].Strict {
+			end++
+		}
+
+		events := make([]*Event, end-start)
+		for i, record := range records[start:end] {
+			events[i] = record.Event
+		}
+
+		var err error
+		if records[start].Endpoint == spoolTrackEndpoint {
+			_, err = s.client.track(ctx, events, TrackOptions{Strict: records[start].Strict, Compression: Gzip})
+		} else {
+			_, err = s.client.importEvents(ctx, events, jsonPayload, ImportOptions{Strict: records[start].Strict, Compression: Gzip})
+		}
+		if err != nil {
+			if isSpoolable(err) || ctx.Err() != nil {
+				return fmt.Errorf("failed to replay spooled events: %w", err)
+			}
+			s.reportError(fmt.Errorf("dropped %d spooled events: %w", len(events), err))
+		}
+
+		start = end
+	}
+	return nil
+}
+
+func (s *spool) close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+	})
+	<-s.done
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closeActive()
+}
+
+// spoolOnFailure writes the events to the spool if err means the api could not be reached
+func (m *ApiClient) spoolOnFailure(endpoint string, strict bool, events []*Event, err error) error {
+	if m.spool == nil {
+		return err
+	}
+	if err == nil {
+		m.spool.wake()
+		return nil
+	}
+	if !isSpoolable(err) {
+		return err
+	}
+
+	if spoolErr := m.spool.append(endpoint, strict, events); spoolErr != nil {
+		return fmt.Errorf("%w, failed to spool events: %v", err, spoolErr)
+	}
+	return SpooledError{Err: err}
+}
+
+// ReplaySpool sends the spooled events now instead of waiting for the replay interval
+func (m *ApiClient) ReplaySpool(ctx context.Context) error {
+	if m.spool == nil {
+		return nil
+	}
+	return m.spool.replay(ctx)
+}
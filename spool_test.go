@@ -0,0 +1,155 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func testSpoolConfig(t *testing.T) SpoolConfig {
+	config := DefaultSpoolConfig(t.TempDir())
+	config.ReplayInterval = time.Hour
+	return config
+}
+
+func spoolSegments(t *testing.T, dir string) []string {
+	segments, err := filepath.Glob(filepath.Join(dir, "*"+spoolSegmentExtension))
+	require.NoError(t, err)
+	return segments
+}
+
+func TestSpool(t *testing.T) {
+	// the endpoint is down until up is set to true
+	setupOutageEndpoint := func(t *testing.T, url string, up *bool, success string) *[]*Event {
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var received []*Event
+		httpmock.RegisterResponder(http.MethodPost, url, func(req *http.Request) (*http.Response, error) {
+			if !*up {
+				return httpmock.NewStringResponse(http.StatusServiceUnavailable, "down"), nil
+			}
+			received = append(received, decodeImportRequest(t, req)...)
+			return httpmock.NewStringResponse(http.StatusOK, success), nil
+		})
+		return &received
+	}
+
+	t.Run("spools failed track calls and replays them", func(t *testing.T) {
+		ctx := context.Background()
+		config := testSpoolConfig(t)
+		mp := NewApiClient("token", WithSpool(config))
+		t.Cleanup(func() { _ = mp.Close(ctx) })
+
+		up := false
+		received := setupOutageEndpoint(t, mp.apiEndpoint+trackURL, &up, `{"error": "", "status": 1}`)
+
+		events := makeEvents(mp, 3)
+		err := mp.Track(ctx, events)
+		spooledError := SpooledError{}
+		require.ErrorAs(t, err, &spooledError)
+		require.Len(t, spoolSegments(t, config.Dir), 1)
+		for _, event := range events {
+			require.NotEmpty(t, event.Properties[propertyInsertID])
+		}
+
+		require.Error(t, mp.ReplaySpool(ctx))
+		require.Len(t, spoolSegments(t, config.Dir), 1)
+
+		up = true
+		require.NoError(t, mp.ReplaySpool(ctx))
+		require.Empty(t, spoolSegments(t, config.Dir))
+		require.Len(t, *received, 3)
+		require.Equal(t, events[0].Properties[propertyInsertID], (*received)[0].Properties[propertyInsertID])
+	})
+
+	t.Run("spools failed imports", func(t *testing.T) {
+		ctx := context.Background()
+		config := testSpoolConfig(t)
+		mp := NewApiClient("token", WithSpool(config))
+		t.Cleanup(func() { _ = mp.Close(ctx) })
+
+		up := false
+		received := setupOutageEndpoint(t, mp.apiEndpoint+importURL, &up, `{"code": 200,"num_records_imported": 2,"status": 1}`)
+
+		_, err := mp.Import(ctx, makeEvents(mp, 2), ImportOptionsRecommend)
+		require.ErrorAs(t, err, &SpooledError{})
+
+		up = true
+		require.NoError(t, mp.ReplaySpool(ctx))
+		require.Len(t, *received, 2)
+	})
+
+	t.Run("does not spool rejected events", func(t *testing.T) {
+		ctx := context.Background()
+		config := testSpoolConfig(t)
+		mp := NewApiClient("token", WithSpool(config))
+		t.Cleanup(func() { _ = mp.Close(ctx) })
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, mp.apiEndpoint+importURL, httpmock.NewStringResponder(http.StatusBadRequest, `{"code": 400, "error": "invalid", "num_records_imported": 0, "failed_records": []}`))
+
+		_, err := mp.Import(ctx, makeEvents(mp, 1), ImportOptionsRecommend)
+		require.ErrorAs(t, err, &ImportFailedValidationError{})
+		require.Empty(t, spoolSegments(t, config.Dir))
+	})
+
+	t.Run("rotates segments and enforces the size cap", func(t *testing.T) {
+		ctx := context.Background()
+		config := testSpoolConfig(t)
+		config.SegmentBytes = 1
+		config.MaxBytes = 1024
+		mp := NewApiClient("token", WithSpool(config))
+		t.Cleanup(func() { _ = mp.Close(ctx) })
+
+		up := false
+		setupOutageEndpoint(t, mp.apiEndpoint+trackURL, &up, "")
+
+		require.ErrorAs(t, mp.Track(ctx, makeEvents(mp, 1)), &SpooledError{})
+		require.ErrorAs(t, mp.Track(ctx, makeEvents(mp, 1)), &SpooledError{})
+		require.Len(t, spoolSegments(t, config.Dir), 2)
+
+		err := mp.Track(ctx, makeEvents(mp, 20))
+		require.Error(t, err)
+		require.False(t, errors.As(err, &SpooledError{}))
+		require.Contains(t, err.Error(), ErrSpoolFull.Error())
+	})
+
+	t.Run("recovers segments left by a previous process", func(t *testing.T) {
+		ctx := context.Background()
+		config := testSpoolConfig(t)
+
+		mp := NewApiClient("token")
+		valid, err := json.Marshal(spoolRecord{Endpoint: spoolTrackEndpoint, Event: mp.NewEvent("valid", EmptyDistinctID, nil)})
+		require.NoError(t, err)
+		segment := fmt.Sprintf("%s\n{not json\n%s\n{\"endpoint\":\"track\",\"ev", valid, valid)
+		require.NoError(t, os.WriteFile(filepath.Join(config.Dir, fmt.Sprintf("%020d%s", 7, spoolSegmentExtension)), []byte(segment), 0o644))
+
+		var reported []error
+		config.OnError = func(err error) {
+			reported = append(reported, err)
+		}
+		mp = NewApiClient("token", WithSpool(config))
+		t.Cleanup(func() { _ = mp.Close(ctx) })
+		require.Equal(t, uint64(8), mp.spool.nextSeq)
+
+		up := true
+		received := setupOutageEndpoint(t, mp.apiEndpoint+trackURL, &up, `{"error": "", "status": 1}`)
+
+		require.NoError(t, mp.ReplaySpool(ctx))
+		require.Len(t, *received, 2)
+		require.Len(t, reported, 1)
+		require.ErrorAs(t, reported[0], &SpoolCorruptionError{})
+		require.Empty(t, spoolSegments(t, config.Dir))
+	})
+}
@@ -0,0 +1,78 @@
+package mixpanel
+
+import (
+	"sync"
+)
+
+// superProperties are the properties added to every event created by NewEvent
+type superProperties struct {
+	mu         sync.RWMutex
+	properties map[string]any
+}
+
+func (s *superProperties) register(properties map[string]any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.properties == nil {
+		s.properties = make(map[string]any, len(properties))
+	}
+	for key, value := range properties {
+		s.properties[key] = value
+	}
+}
+
+func (s *superProperties) unregister(keys []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.properties, key)
+	}
+}
+
+// merge adds the super properties that are not already set in properties
+func (s *superProperties) merge(properties map[string]any) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for key, value := range s.properties {
+		if _, ok := properties[key]; !ok {
+			properties[key] = value
+		}
+	}
+}
+
+func (s *superProperties) snapshot() map[string]any {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	properties := make(map[string]any, len(s.properties))
+	for key, value := range s.properties {
+		properties[key] = value
+	}
+	return properties
+}
+
+// WithSuperProperties adds the properties to every event created by NewEvent, including the feature flags exposure events
+// Properties set on the event take precedence over super properties
+func WithSuperProperties(properties map[string]any) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.superProperties.register(properties)
+	}
+}
+
+// Register adds or replaces super properties, it is safe to call while events are being created
+func (m *ApiClient) Register(properties map[string]any) {
+	m.superProperties.register(properties)
+}
+
+// Unregister removes the super properties with the keys
+func (m *ApiClient) Unregister(keys ...string) {
+	m.superProperties.unregister(keys)
+}
+
+// SuperProperties returns a copy of the current super properties
+func (m *ApiClient) SuperProperties() map[string]any {
+	return m.superProperties.snapshot()
+}
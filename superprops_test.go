@@ -0,0 +1,101 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/mixpanel/mixpanel-go/v2/flags"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuperProperties(t *testing.T) {
+	t.Run("added to new events", func(t *testing.T) {
+		mp := NewApiClient("token", WithSuperProperties(map[string]any{
+			"service": "billing",
+			"region":  "eu",
+		}))
+
+		event := mp.NewEvent("event", "user-1", nil)
+		require.Equal(t, "billing", event.Properties["service"])
+		require.Equal(t, "eu", event.Properties["region"])
+	})
+
+	t.Run("explicit properties take precedence", func(t *testing.T) {
+		mp := NewApiClient("token", WithSuperProperties(map[string]any{
+			"region":           "eu",
+			propertyDistinctID: "super",
+			propertyToken:      "super",
+		}))
+
+		event := mp.NewEvent("event", "user-1", map[string]any{"region": "us"})
+		require.Equal(t, "us", event.Properties["region"])
+		require.Equal(t, "user-1", event.Properties[propertyDistinctID])
+		require.Equal(t, "token", event.Properties[propertyToken])
+	})
+
+	t.Run("register and unregister", func(t *testing.T) {
+		mp := NewApiClient("token", WithSuperProperties(map[string]any{"service": "billing"}))
+		mp.Register(map[string]any{"build": "1.2.3", "service": "payments"})
+		require.Equal(t, map[string]any{"build": "1.2.3", "service": "payments"}, mp.SuperProperties())
+
+		mp.Unregister("service", "missing")
+		event := mp.NewEvent("event", "user-1", nil)
+		require.Equal(t, "1.2.3", event.Properties["build"])
+		require.NotContains(t, event.Properties, "service")
+	})
+
+	t.Run("snapshot is a copy", func(t *testing.T) {
+		mp := NewApiClient("token", WithSuperProperties(map[string]any{"service": "billing"}))
+		mp.SuperProperties()["service"] = "changed"
+		require.Equal(t, "billing", mp.SuperProperties()["service"])
+	})
+
+	t.Run("safe for concurrent use", func(t *testing.T) {
+		mp := NewApiClient("token")
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			i := i
+			wg.Add(2)
+			go func() {
+				defer wg.Done()
+				key := fmt.Sprintf("key_%d", i)
+				mp.Register(map[string]any{key: i})
+				mp.Unregister(key)
+			}()
+			go func() {
+				defer wg.Done()
+				_ = mp.NewEvent("event", "user-1", nil)
+			}()
+		}
+		wg.Wait()
+		require.Empty(t, mp.SuperProperties())
+	})
+
+	t.Run("added to feature flag exposure events", func(t *testing.T) {
+		mp := NewApiClient("token",
+			WithSuperProperties(map[string]any{"service": "billing"}),
+			WithRemoteFlags(flags.DefaultRemoteFlagsConfig()),
+		)
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		var tracked []*Event
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, trackURL), func(req *http.Request) (*http.Response, error) {
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&tracked))
+			return httpmock.NewStringResponse(http.StatusOK, `{"error": "", "status": 1}`), nil
+		})
+
+		variant := "on"
+		mp.RemoteFlags.TrackExposureEvent(context.Background(), "flag", flags.SelectedVariant{VariantKey: &variant}, flags.FlagContext{"distinct_id": "user-1"})
+		require.Len(t, tracked, 1)
+		require.Equal(t, "billing", tracked[0].Properties["service"])
+		require.Equal(t, "flag", tracked[0].Properties["Experiment name"])
+	})
+}
@@ -0,0 +1,390 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// DesiredProfile is the state a people or group profile must have after a sync
+// The properties that are not listed are left as they are
+type DesiredProfile struct {
+	// ID is the distinct_id, or the group id for group profiles
+	ID string
+	// Set are the properties that must have the value
+	Set map[string]any
+	// Unset are the properties that must not exist
+	Unset []string
+	// Union are the list properties that must contain the values
+	Union map[string][]any
+	// Remove are the list properties that must not contain the values
+	Remove map[string][]any
+}
+
+// ProfileChange are the operations a sync sends for a profile
+type ProfileChange struct {
+	ID     string
+	Set    map[string]any
+	Unset  []string
+	Union  map[string][]any
+	Remove map[string][]any
+}
+
+func (c ProfileChange) empty() bool {
+	return len(c.Set) == 0 && len(c.Unset) == 0 && len(c.Union) == 0 && len(c.Remove) == 0
+}
+
+type SyncOptions struct {
+	// DryRun computes the changes without sending them
+	DryRun bool
+}
+
+type SyncReport struct {
+	// Checked is the number of desired profiles compared with their current state
+	Checked int
+	// Changed are the changes of the profiles that did not match the desired state, in the order of the desired profiles
+	Changed []ProfileChange
+	// Unchanged is the number of profiles already in the desired state
+	Unchanged int
+}
+
+// SyncPeopleProfiles reads the current people profiles with the engage query and only sends the $set, $unset, $union and $remove
+// operations needed to reach the desired state
+// With WithRedaction the ids and values are compared as they are stored after the redaction, the report has the values of desired
+// Returns the report with a PeopleBatchError if some of the changes failed
+func (a *ApiClient) SyncPeopleProfiles(ctx context.Context, desired []DesiredProfile, options SyncOptions) (*SyncReport, error) {
+	current := func(ctx context.Context, query ProfileQuery) (map[string]map[string]any, error) {
+		profiles, err := a.QueryProfiles(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		found := make(map[string]map[string]any)
+		for profiles.Next() {
+			profile := profiles.Profile()
+			found[profile.DistinctID] = profile.Properties
+		}
+		return found, profiles.Err()
+	}
+
+	apply := func(ctx context.Context, changes []ProfileChange) error {
+		batch := a.NewPeopleBatch()
+		for _, change := range changes {
+			if len(change.Set) > 0 {
+				batch.Set(NewPeopleProperties(change.ID, change.Set))
+			}
+			if len(change.Unset) > 0 {
+				batch.Unset(change.ID, change.Unset)
+			}
+			if len(change.Union) > 0 {
+				batch.Union(change.ID, unionOperation(change.Union))
+			}
+			for _, remove := range removeOperations(change.Remove) {
+				batch.Remove(change.ID, remove)
+			}
+		}
+		return batch.Flush(ctx)
+	}
+
+	return a.syncProfiles(ctx, desired, options, current, apply)
+}
+
+// SyncGroupProfiles is SyncPeopleProfiles for the group profiles of a group key
+// groupKey is the group key the changes are sent to, like "company", and dataGroupID is its id used to read the profiles, see QueryGroupProfiles
+// Returns the report with a GroupBatchError if some of the changes failed
+func (a *ApiClient) SyncGroupProfiles(ctx context.Context, groupKey, dataGroupID string, desired []DesiredProfile, options SyncOptions) (*SyncReport, error) {
+	current := func(ctx context.Context, query ProfileQuery) (map[string]map[string]any, error) {
+		groups, err := a.QueryGroupProfiles(ctx, dataGroupID, query)
+		if err != nil {
+			return nil, err
+		}
+		found := make(map[string]map[string]any)
+		for groups.Next() {
+			group := groups.Profile()
+			found[group.GroupID] = group.Properties
+		}
+		return found, groups.Err()
+	}
+
+	apply := func(ctx context.Context, changes []ProfileChange) error {
+		batch := a.NewGroupBatch()
+		for _, change := range changes {
+			if len(change.Set) > 0 {
+				batch.Set(groupKey, change.ID, change.Set)
+			}
+			if len(change.Unset) > 0 {
+				batch.Unset(groupKey, change.ID, change.Unset)
+			}
+			if len(change.Union) > 0 {
+				batch.Union(groupKey, change.ID, unionOperation(change.Union))
+			}
+			for _, remove := range removeOperations(change.Remove) {
+				batch.Remove(groupKey, change.ID, remove)
+			}
+		}
+		return batch.Flush(ctx)
+	}
+
+	return a.syncProfiles(ctx, desired, options, current, apply)
+}
+
+func (a *ApiClient) syncProfiles(
+	ctx context.Context,
+	desired []DesiredProfile,
+	options SyncOptions,
+	current func(ctx context.Context, query ProfileQuery) (map[string]map[string]any, error),
+	apply func(ctx context.Context, changes []ProfileChange) error,
+) (*SyncReport, error) {
+	report := &SyncReport{}
+
+	for _, c := range splitChunks(len(desired), MaxPeopleEvents) {
+		chunk := desired[c.start:c.end]
+		query, err := syncQuery(chunk)
+		if err != nil {
+			return report, err
+		}
+		// the profiles are stored with the redacted ids and values
+		for i := range query.DistinctIDs {
+			query.DistinctIDs[i] = a.redactID(query.DistinctIDs[i])
+		}
+		profiles, err := current(ctx, query)
+		if err != nil {
+			return report, fmt.Errorf("failed to read current profiles: %w", err)
+		}
+
+		var changes []ProfileChange
+		for _, d := range chunk {
+			redacted := a.redactDesired(d)
+			change, err := diffProfile(d, redacted, profiles[redacted.ID])
+			if err != nil {
+				return report, fmt.Errorf("failed to compare profile %q: %w", d.ID, err)
+			}
+			report.Checked++
+			if change.empty() {
+				report.Unchanged++
+				continue
+			}
+			changes = append(changes, change)
+		}
+		report.Changed = append(report.Changed, changes...)
+
+		if options.DryRun || len(changes) == 0 {
+			continue
+		}
+		if err := apply(ctx, changes); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
+
+// syncQuery looks up the profiles of the chunk and only returns the properties the sync compares
+func syncQuery(chunk []DesiredProfile) (ProfileQuery, error) {
+	ids := make(map[string]bool, len(chunk))
+	properties := make(map[string]bool)
+	query := ProfileQuery{}
+	for _, d := range chunk {
+		if d.ID == "" {
+			return query, fmt.Errorf("desired profile has no id")
+		}
+		if ids[d.ID] {
+			return query, fmt.Errorf("duplicate desired profile %q", d.ID)
+		}
+		ids[d.ID] = true
+		query.DistinctIDs = append(query.DistinctIDs, d.ID)
+
+		for key := range d.Set {
+			properties[key] = true
+		}
+		for _, key := range d.Unset {
+			properties[key] = true
+		}
+		for key := range d.Union {
+			properties[key] = true
+		}
+		for key := range d.Remove {
+			properties[key] = true
+		}
+	}
+
+	for key := range properties {
+		query.OutputProperties = append(query.OutputProperties, key)
+	}
+	sort.Strings(query.OutputProperties)
+	return query, nil
+}
+
+// redactDesired returns the desired profile as it is stored with the redaction policy of the client
+// The list values are redacted one by one, properties removed by the policy are left out
+func (a *ApiClient) redactDesired(d DesiredProfile) DesiredProfile {
+	if a.redaction == nil {
+		return d
+	}
+
+	redacted := DesiredProfile{
+		ID:    a.redactID(d.ID),
+		Set:   a.redactProperties(d.Set),
+		Unset: a.redactKeys(d.Unset),
+	}
+	redactList := func(list map[string][]any) map[string][]any {
+		if list == nil {
+			return nil
+		}
+		redactedList := make(map[string][]any, len(list))
+		for key, values := range list {
+			for _, value := range values {
+				if value, ok := a.redactProperties(map[string]any{key: value})[key]; ok {
+					redactedList[key] = append(redactedList[key], value)
+				}
+			}
+		}
+		return redactedList
+	}
+	redacted.Union = redactList(d.Union)
+	redacted.Remove = redactList(d.Remove)
+	return redacted
+}
+
+// diffProfile returns the operations that change the current properties to the desired state
+// The redacted profile is compared with the current properties, the change has the values of desired since the batches redact them
+func diffProfile(desired, redacted DesiredProfile, current map[string]any) (ProfileChange, error) {
+	change := ProfileChange{ID: desired.ID}
+
+	for key, value := range desired.Set {
+		redactedValue, sent := redacted.Set[key]
+		if !sent {
+			continue
+		}
+		currentValue, ok := current[key]
+		if ok {
+			equal, err := jsonEqual(redactedValue, currentValue)
+			if err != nil {
+				return change, err
+			}
+			if equal {
+				continue
+			}
+		}
+		if change.Set == nil {
+			change.Set = make(map[string]any)
+		}
+		change.Set[key] = value
+	}
+
+	for _, key := range redacted.Unset {
+		if _, ok := current[key]; ok {
+			change.Unset = append(change.Unset, key)
+		}
+	}
+
+	for key, values := range desired.Union {
+		for i, value := range values {
+			if i >= len(redacted.Union[key]) {
+				break
+			}
+			contains, err := listContains(current[key], redacted.Union[key][i])
+			if err != nil {
+				return change, err
+			}
+			if contains {
+				continue
+			}
+			if change.Union == nil {
+				change.Union = make(map[string][]any)
+			}
+			change.Union[key] = append(change.Union[key], value)
+		}
+	}
+
+	for key, values := range desired.Remove {
+		for i, value := range values {
+			if i >= len(redacted.Remove[key]) {
+				break
+			}
+			contains, err := listContains(current[key], redacted.Remove[key][i])
+			if err != nil {
+				return change, err
+			}
+			if !contains {
+				continue
+			}
+			if change.Remove == nil {
+				change.Remove = make(map[string][]any)
+			}
+			change.Remove[key] = append(change.Remove[key], value)
+		}
+	}
+
+	return change, nil
+}
+
+// jsonEqual compares the values as mixpanel stores them, so int 1 and float64 1 are equal
+func jsonEqual(a, b any) (bool, error) {
+	normalizedA, err := normalizeJson(a)
+	if err != nil {
+		return false, err
+	}
+	normalizedB, err := normalizeJson(b)
+	if err != nil {
+		return false, err
+	}
+	return reflect.DeepEqual(normalizedA, normalizedB), nil
+}
+
+func normalizeJson(value any) (any, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var normalized any
+	if err := json.Unmarshal(data, &normalized); err != nil {
+		return nil, err
+	}
+	return normalized, nil
+}
+
+func listContains(list any, value any) (bool, error) {
+	values, ok := list.([]any)
+	if !ok {
+		return false, nil
+	}
+	for _, v := range values {
+		equal, err := jsonEqual(v, value)
+		if err != nil {
+			return false, err
+		}
+		if equal {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func unionOperation(union map[string][]any) map[string]any {
+	operation := make(map[string]any, len(union))
+	for key, values := range union {
+		operation[key] = values
+	}
+	return operation
+}
+
+// removeOperations splits the values since $remove takes a single value per property
+func removeOperations(remove map[string][]any) []map[string]any {
+	keys := make([]string, 0, len(remove))
+	for key := range remove {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var operations []map[string]any
+	for _, key := range keys {
+		for i, value := range remove[key] {
+			if i >= len(operations) {
+				operations = append(operations, make(map[string]any))
+			}
+			operations[i][key] = value
+		}
+	}
+	return operations
+}
@@ -0,0 +1,220 @@
+package mixpanel
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncPeopleProfiles(t *testing.T) {
+	pages := [][]*Profile{
+		{
+			{DistinctID: "user-1", Properties: map[string]any{
+				"plan":  "free",
+				"seats": float64(3),
+				"old":   "value",
+				"tags":  []any{"a", "b"},
+			}},
+			{DistinctID: "user-2", Properties: map[string]any{
+				"plan":  "pro",
+				"seats": float64(3),
+				"tags":  []any{"a"},
+			}},
+		},
+	}
+	desired := []DesiredProfile{
+		{
+			ID:     "user-1",
+			Set:    map[string]any{"plan": "pro", "seats": 3},
+			Unset:  []string{"old", "missing"},
+			Union:  map[string][]any{"tags": {"a", "c"}},
+			Remove: map[string][]any{"tags": {"b", "d"}},
+		},
+		{
+			ID:     "user-2",
+			Set:    map[string]any{"plan": "pro", "seats": 3},
+			Unset:  []string{"old"},
+			Union:  map[string][]any{"tags": {"a"}},
+			Remove: map[string][]any{"tags": {"b"}},
+		},
+		{
+			ID:  "user-3",
+			Set: map[string]any{"plan": "free"},
+		},
+	}
+	expected := []ProfileChange{
+		{
+			ID:     "user-1",
+			Set:    map[string]any{"plan": "pro"},
+			Unset:  []string{"old"},
+			Union:  map[string][]any{"tags": {"c"}},
+			Remove: map[string][]any{"tags": {"b"}},
+		},
+		{
+			ID:  "user-3",
+			Set: map[string]any{"plan": "free"},
+		},
+	}
+	setupEngageEndpoint := func(t *testing.T, client *ApiClient) *[]map[string]any {
+		setupEngageQueryEndpoint(t, client, pages, func(page int, req *http.Request) {
+			var distinctIDs []string
+			require.NoError(t, json.Unmarshal([]byte(req.PostForm.Get("distinct_ids")), &distinctIDs))
+			require.Equal(t, []string{"user-1", "user-2", "user-3"}, distinctIDs)
+			require.Equal(t, `["missing","old","plan","seats","tags"]`, req.PostForm.Get("output_properties"))
+		})
+
+		var sent []map[string]any
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", client.apiEndpoint, peopleBatchURL), func(req *http.Request) (*http.Response, error) {
+			var records []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&records))
+			sent = append(sent, records...)
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		})
+		return &sent
+	}
+
+	t.Run("only sends the changes", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		sent := setupEngageEndpoint(t, mp)
+
+		report, err := mp.SyncPeopleProfiles(ctx, desired, SyncOptions{})
+		require.NoError(t, err)
+		require.Equal(t, 3, report.Checked)
+		require.Equal(t, 1, report.Unchanged)
+		require.Equal(t, expected, report.Changed)
+
+		require.Len(t, *sent, 5)
+		require.Equal(t, "user-1", (*sent)[0]["$distinct_id"])
+		require.Equal(t, map[string]any{"plan": "pro"}, (*sent)[0]["$set"])
+		require.Equal(t, []any{"old"}, (*sent)[1]["$unset"])
+		require.Equal(t, map[string]any{"tags": []any{"c"}}, (*sent)[2]["$union"])
+		require.Equal(t, map[string]any{"tags": "b"}, (*sent)[3]["$remove"])
+		require.Equal(t, "user-3", (*sent)[4]["$distinct_id"])
+		require.Equal(t, map[string]any{"plan": "free"}, (*sent)[4]["$set"])
+	})
+
+	t.Run("dry run does not send the changes", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		sent := setupEngageEndpoint(t, mp)
+
+		report, err := mp.SyncPeopleProfiles(ctx, desired, SyncOptions{DryRun: true})
+		require.NoError(t, err)
+		require.Equal(t, expected, report.Changed)
+		require.Empty(t, *sent)
+	})
+
+	t.Run("reports failed changes", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		setupEngageQueryEndpoint(t, mp, pages, nil)
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, peopleBatchURL), httpmock.NewStringResponder(http.StatusOK, "0"))
+
+		report, err := mp.SyncPeopleProfiles(ctx, desired, SyncOptions{})
+		batchError := PeopleBatchError{}
+		require.ErrorAs(t, err, &batchError)
+		require.Len(t, batchError.Records, 5)
+		require.Equal(t, expected, report.Changed)
+	})
+
+	t.Run("compares the redacted ids and values", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"), WithRedaction(RedactionPolicy{
+			HashKeys: []string{propertyDistinctID},
+			HashSalt: "salt",
+			Patterns: []*regexp.Regexp{EmailPattern},
+		}))
+		stored := mp.redactID("user-1")
+		setupEngageQueryEndpoint(t, mp, [][]*Profile{
+			{{DistinctID: stored, Properties: map[string]any{"email": "[REDACTED]", "plan": "free", "emails": []any{"[REDACTED]"}}}},
+		}, func(page int, req *http.Request) {
+			require.Equal(t, fmt.Sprintf(`["%s"]`, stored), req.PostForm.Get("distinct_ids"))
+		})
+
+		var sent []map[string]any
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, peopleBatchURL), func(req *http.Request) (*http.Response, error) {
+			var records []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&records))
+			sent = append(sent, records...)
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		})
+
+		report, err := mp.SyncPeopleProfiles(ctx, []DesiredProfile{{
+			ID:    "user-1",
+			Set:   map[string]any{"email": "user@example.com", "plan": "pro"},
+			Union: map[string][]any{"emails": {"user@example.com"}},
+		}}, SyncOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []ProfileChange{{ID: "user-1", Set: map[string]any{"plan": "pro"}}}, report.Changed)
+
+		require.Len(t, sent, 1)
+		require.Equal(t, stored, sent[0]["$distinct_id"])
+		require.Equal(t, map[string]any{"plan": "pro"}, sent[0]["$set"])
+	})
+
+	t.Run("invalid desired profiles", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+
+		_, err := mp.SyncPeopleProfiles(ctx, []DesiredProfile{{Set: map[string]any{"plan": "pro"}}}, SyncOptions{})
+		require.Error(t, err)
+
+		_, err = mp.SyncPeopleProfiles(ctx, []DesiredProfile{{ID: "user-1"}, {ID: "user-1"}}, SyncOptions{})
+		require.Error(t, err)
+	})
+}
+
+func TestSyncGroupProfiles(t *testing.T) {
+	t.Run("reads with the data group id and writes with the group key", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", ApiSecret("api-secret"))
+		pages := [][]*Profile{
+			{
+				{DistinctID: "acme", Properties: map[string]any{"plan": "free", "regions": []any{"eu", "us"}}},
+			},
+		}
+		setupEngageQueryEndpoint(t, mp, pages, func(page int, req *http.Request) {
+			require.Equal(t, "7", req.PostForm.Get("data_group_id"))
+		})
+
+		var sent []map[string]any
+		httpmock.RegisterResponder(http.MethodPost, fmt.Sprintf("%s%s", mp.apiEndpoint, groupsBatchURL), func(req *http.Request) (*http.Response, error) {
+			var records []map[string]any
+			require.NoError(t, json.NewDecoder(req.Body).Decode(&records))
+			sent = append(sent, records...)
+			return httpmock.NewStringResponse(http.StatusOK, "1"), nil
+		})
+
+		report, err := mp.SyncGroupProfiles(ctx, "company", "7", []DesiredProfile{
+			{
+				ID:     "acme",
+				Set:    map[string]any{"plan": "pro"},
+				Remove: map[string][]any{"regions": {"eu", "us"}},
+			},
+		}, SyncOptions{})
+		require.NoError(t, err)
+		require.Equal(t, []ProfileChange{
+			{
+				ID:     "acme",
+				Set:    map[string]any{"plan": "pro"},
+				Remove: map[string][]any{"regions": {"eu", "us"}},
+			},
+		}, report.Changed)
+
+		require.Len(t, sent, 3)
+		for _, record := range sent {
+			require.Equal(t, "company", record["$group_key"])
+			require.Equal(t, "acme", record["$group_id"])
+		}
+		require.Equal(t, map[string]any{"plan": "pro"}, sent[0]["$set"])
+		require.Equal(t, map[string]any{"regions": "eu"}, sent[1]["$remove"])
+		require.Equal(t, map[string]any{"regions": "us"}, sent[2]["$remove"])
+	})
+}
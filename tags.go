@@ -0,0 +1,396 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	structTag = "mixpanel"
+
+	tagOmitEmpty  = "omitempty"
+	tagDistinctID = "distinct_id"
+	tagInsertID   = "insert_id"
+	tagTime       = "time"
+
+	// mixpanel date properties format
+	// https://docs.mixpanel.com/docs/data-structure/property-reference/data-type#date
+	datePropertyLayout = "2006-01-02T15:04:05"
+)
+
+var (
+	timeType = reflect.TypeOf(time.Time{})
+
+	// structPlans caches the *structPlan of every struct type that was encoded or decoded
+	structPlans sync.Map
+)
+
+type fieldRole int
+
+const (
+	roleProperty fieldRole = iota
+	roleDistinctID
+	roleInsertID
+	roleTime
+)
+
+var roleNames = map[fieldRole]string{
+	roleDistinctID: tagDistinctID,
+	roleInsertID:   tagInsertID,
+	roleTime:       "the event time",
+}
+
+type structField struct {
+	index     []int
+	name      string
+	omitEmpty bool
+	role      fieldRole
+}
+
+type structPlan struct {
+	fields []structField
+}
+
+// planFor returns the cached plan of the struct type, building it on first use
+func planFor(t reflect.Type) (*structPlan, error) {
+	if plan, ok := structPlans.Load(t); ok {
+		return plan.(*structPlan), nil
+	}
+
+	plan := &structPlan{}
+	if err := plan.addFields(t, nil); err != nil {
+		return nil, err
+	}
+	actual, _ := structPlans.LoadOrStore(t, plan)
+	return actual.(*structPlan), nil
+}
+
+func (p *structPlan) addFields(t reflect.Type, index []int) error {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		tag, hasTag := field.Tag.Lookup(structTag)
+		if tag == "-" {
+			continue
+		}
+
+		// untagged embedded structs are flattened like encoding/json does
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && !hasTag && fieldType.Kind() == reflect.Struct && fieldType != timeType {
+			if err := p.addFields(fieldType, fieldIndex); err != nil {
+				return err
+			}
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		f := structField{
+			index: fieldIndex,
+			name:  parts[0],
+		}
+		if f.name == "" {
+			f.name = field.Name
+		}
+
+		for _, option := range parts[1:] {
+			switch option {
+			case tagOmitEmpty:
+				f.omitEmpty = true
+			case tagDistinctID:
+				f.role = roleDistinctID
+			case tagInsertID:
+				f.role = roleInsertID
+			case tagTime:
+				f.role = roleTime
+			default:
+				return fmt.Errorf("unknown %s tag option %q on field %s", structTag, option, field.Name)
+			}
+		}
+		// time.Time fields map to the event time unless they are tagged with another property name
+		if f.role == roleProperty && fieldType == timeType && (parts[0] == "" || parts[0] == propertyTime) {
+			f.role = roleTime
+		}
+
+		switch f.role {
+		case roleDistinctID, roleInsertID:
+			if fieldType.Kind() != reflect.String {
+				return fmt.Errorf("field %s must be a string to be used as %s", field.Name, roleNames[f.role])
+			}
+		case roleTime:
+			if fieldType != timeType {
+				return fmt.Errorf("field %s must be a time.Time to be used as the event time", field.Name)
+			}
+		}
+
+		if f.role != roleProperty {
+			for _, other := range p.fields {
+				if other.role == f.role {
+					return fmt.Errorf("fields %s and %s can't both be used as %s", other.name, f.name, roleNames[f.role])
+				}
+			}
+		}
+
+		p.fields = append(p.fields, f)
+	}
+	return nil
+}
+
+func structValue(v any) (reflect.Value, error) {
+	value := reflect.ValueOf(v)
+	for value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return reflect.Value{}, errors.New("struct is nil")
+		}
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		return reflect.Value{}, fmt.Errorf("expected a struct, got %s", value.Kind())
+	}
+	return value, nil
+}
+
+// fieldByIndex returns false if the field is behind a nil embedded pointer
+func fieldByIndex(value reflect.Value, index []int) (reflect.Value, bool) {
+	field, err := value.FieldByIndexErr(index)
+	return field, err == nil
+}
+
+// NewEventFromStruct creates a new event with the properties of the struct tagged with `mixpanel:"name,options"`
+// Options are omitempty, distinct_id, insert_id and time
+// An untagged time.Time field, or one tagged time, is the event time, other time.Time fields are sent as dates
+// Untagged exported fields use the field name, fields tagged "-" are skipped
+func (m *ApiClient) NewEventFromStruct(name string, v any) (*Event, error) {
+	value, err := structValue(v)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := planFor(value.Type())
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		distinctID string
+		insertID   string
+		eventTime  *time.Time
+	)
+	properties := make(map[string]any)
+	for _, f := range plan.fields {
+		// the distinct_id, insert_id and time fields are never sent empty
+		field, ok := fieldByIndex(value, f.index)
+		if ok && f.role != roleProperty {
+			field, ok = derefField(field)
+		}
+		if !ok || ((f.omitEmpty || f.role != roleProperty) && field.IsZero()) {
+			continue
+		}
+
+		switch f.role {
+		case roleDistinctID:
+			distinctID = field.String()
+		case roleInsertID:
+			insertID = field.String()
+		case roleTime:
+			t := field.Interface().(time.Time)
+			eventTime = &t
+		default:
+			property, err := encodeProperty(field)
+			if err != nil {
+				return nil, fmt.Errorf("failed to encode property %s: %w", f.name, err)
+			}
+			properties[f.name] = property
+		}
+	}
+
+	event := m.NewEvent(name, distinctID, properties)
+	if insertID != "" {
+		event.AddInsertID(insertID)
+	}
+	if eventTime != nil {
+		event.AddTime(*eventTime)
+	}
+	return event, nil
+}
+
+// derefField returns the value the pointer field points to, false if it is nil
+func derefField(field reflect.Value) (reflect.Value, bool) {
+	for field.Kind() == reflect.Pointer {
+		if field.IsNil() {
+			return field, false
+		}
+		field = field.Elem()
+	}
+	return field, true
+}
+
+func encodeProperty(field reflect.Value) (any, error) {
+	for field.Kind() == reflect.Pointer || field.Kind() == reflect.Interface {
+		if field.IsNil() {
+			return nil, nil
+		}
+		field = field.Elem()
+	}
+
+	if field.Type() == timeType {
+		return field.Interface().(time.Time).UTC().Format(datePropertyLayout), nil
+	}
+
+	if field.Kind() == reflect.Struct {
+		plan, err := planFor(field.Type())
+		if err != nil {
+			return nil, err
+		}
+		nested := make(map[string]any)
+		for _, f := range plan.fields {
+			nestedField, ok := fieldByIndex(field, f.index)
+			if !ok || (f.omitEmpty && nestedField.IsZero()) {
+				continue
+			}
+			property, err := encodeProperty(nestedField)
+			if err != nil {
+				return nil, err
+			}
+			nested[f.name] = property
+		}
+		return nested, nil
+	}
+
+	return field.Interface(), nil
+}
+
+// DecodeProperties decodes the event properties into the struct pointed by v using the `mixpanel` tags
+// It is the reverse of NewEventFromStruct and can be used on the events returned by Export
+func (e *Event) DecodeProperties(v any) error {
+	value := reflect.ValueOf(v)
+	if value.Kind() != reflect.Pointer || value.IsNil() {
+		return errors.New("decode target must be a non nil pointer to a struct")
+	}
+	value, err := structValue(v)
+	if err != nil {
+		return err
+	}
+	plan, err := planFor(value.Type())
+	if err != nil {
+		return err
+	}
+
+	for _, f := range plan.fields {
+		var property any
+		var ok bool
+		switch f.role {
+		case roleDistinctID:
+			property, ok = e.Properties[propertyDistinctID]
+		case roleInsertID:
+			property, ok = e.Properties[propertyInsertID]
+		case roleTime:
+			property, ok = e.Properties[propertyTime]
+		default:
+			property, ok = e.Properties[f.name]
+		}
+		if !ok || property == nil {
+			continue
+		}
+
+		field, err := allocFieldByIndex(value, f.index)
+		if err != nil {
+			return err
+		}
+		if err := decodeProperty(field, f.role, property); err != nil {
+			return fmt.Errorf("failed to decode property %s: %w", f.name, err)
+		}
+	}
+	return nil
+}
+
+// allocFieldByIndex returns the field allocating the nil embedded pointers on the way
+func allocFieldByIndex(value reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && value.Kind() == reflect.Pointer {
+			if value.IsNil() {
+				if !value.CanSet() {
+					return reflect.Value{}, errors.New("can't set embedded pointer to unexported struct")
+				}
+				value.Set(reflect.New(value.Type().Elem()))
+			}
+			value = value.Elem()
+		}
+		value = value.Field(x)
+	}
+	return value, nil
+}
+
+func decodeProperty(field reflect.Value, role fieldRole, property any) error {
+	target := field
+	for target.Kind() == reflect.Pointer {
+		if target.IsNil() {
+			target.Set(reflect.New(target.Type().Elem()))
+		}
+		target = target.Elem()
+	}
+
+	if role == roleTime {
+		epoch, err := toFloat(property)
+		if err != nil {
+			return err
+		}
+		target.Set(reflect.ValueOf(timeFromEpoch(epoch)))
+		return nil
+	}
+
+	if target.Type() == timeType {
+		s, ok := property.(string)
+		if !ok {
+			return fmt.Errorf("expected a date string, got %T", property)
+		}
+		t, err := time.Parse(datePropertyLayout, s)
+		if err != nil {
+			if t, err = time.Parse(time.RFC3339, s); err != nil {
+				return err
+			}
+		}
+		target.Set(reflect.ValueOf(t))
+		return nil
+	}
+
+	if target.Kind() == reflect.Struct {
+		nested, ok := property.(map[string]any)
+		if !ok {
+			return fmt.Errorf("expected an object, got %T", property)
+		}
+		return (&Event{Properties: nested}).DecodeProperties(target.Addr().Interface())
+	}
+
+	// json handles the conversions from the types the json decoder produced
+	data, err := json.Marshal(property)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target.Addr().Interface())
+}
+
+func toFloat(value any) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case int64:
+		return float64(v), nil
+	case int:
+		return float64(v), nil
+	case json.Number:
+		return v.Float64()
+	default:
+		return 0, fmt.Errorf("expected a unix timestamp, got %T", value)
+	}
+}
@@ -0,0 +1,218 @@
+package mixpanel
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testAddress struct {
+	City    string `mixpanel:"city"`
+	Country string `mixpanel:"country,omitempty"`
+}
+
+type testCommon struct {
+	UserID string `mixpanel:"user_id,distinct_id"`
+}
+
+type testPointerRoles struct {
+	UserID  *string    `mixpanel:"user_id,distinct_id"`
+	EventID *string    `mixpanel:"event_id,insert_id"`
+	At      *time.Time `mixpanel:"time"`
+	Plan    string     `mixpanel:"plan"`
+}
+
+type testSignup struct {
+	testCommon
+	EventID   string      `mixpanel:"event_id,insert_id"`
+	At        time.Time   `mixpanel:"time"`
+	Plan      string      `mixpanel:"plan_name,omitempty"`
+	Seats     int         `mixpanel:"seats"`
+	Trial     *bool       `mixpanel:"trial,omitempty"`
+	Tags      []string    `mixpanel:"tags,omitempty"`
+	RenewsAt  time.Time   `mixpanel:"renews_at,omitempty"`
+	Address   testAddress `mixpanel:"address"`
+	Internal  string      `mixpanel:"-"`
+	Referrer  string
+	unexposed string
+}
+
+func TestNewEventFromStruct(t *testing.T) {
+	t.Run("encodes tagged fields", func(t *testing.T) {
+		mp := NewApiClient("token")
+		at := time.Unix(1_700_000_000, 0)
+		event, err := mp.NewEventFromStruct("signup", &testSignup{
+			testCommon: testCommon{UserID: "user-1"},
+			EventID:    "event-1",
+			At:         at,
+			Plan:       "pro",
+			Seats:      3,
+			RenewsAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Address:    testAddress{City: "Lisbon"},
+			Internal:   "secret",
+			Referrer:   "google",
+			unexposed:  "hidden",
+		})
+		require.NoError(t, err)
+
+		require.Equal(t, "signup", event.Name)
+		require.Equal(t, "user-1", event.Properties[propertyDistinctID])
+		require.Equal(t, "event-1", event.Properties[propertyInsertID])
+		require.Equal(t, at.UnixMilli(), event.Properties[propertyTime])
+		require.Equal(t, "token", event.Properties[propertyToken])
+		require.Equal(t, "pro", event.Properties["plan_name"])
+		require.Equal(t, 3, event.Properties["seats"])
+		require.Equal(t, "2024-01-02T03:04:05", event.Properties["renews_at"])
+		require.Equal(t, map[string]any{"city": "Lisbon"}, event.Properties["address"])
+		require.Equal(t, "google", event.Properties["Referrer"])
+
+		for _, key := range []string{"trial", "tags", "Internal", "unexposed", "user_id", "event_id"} {
+			require.NotContains(t, event.Properties, key)
+		}
+	})
+
+	t.Run("omitempty skips zero values", func(t *testing.T) {
+		mp := NewApiClient("token")
+		event, err := mp.NewEventFromStruct("signup", testSignup{})
+		require.NoError(t, err)
+		require.NotContains(t, event.Properties, "plan_name")
+		require.NotContains(t, event.Properties, propertyTime)
+		require.NotContains(t, event.Properties, propertyInsertID)
+		require.Equal(t, 0, event.Properties["seats"])
+	})
+
+	t.Run("untagged time field is the event time", func(t *testing.T) {
+		mp := NewApiClient("token")
+		at := time.Unix(1_700_000_000, 0)
+		event, err := mp.NewEventFromStruct("login", struct {
+			DistinctID string `mixpanel:",distinct_id"`
+			Timestamp  time.Time
+		}{DistinctID: "user-1", Timestamp: at})
+		require.NoError(t, err)
+		require.Equal(t, at.UnixMilli(), event.Properties[propertyTime])
+		require.NotContains(t, event.Properties, "Timestamp")
+	})
+
+	t.Run("pointer role fields", func(t *testing.T) {
+		mp := NewApiClient("token")
+		userID, eventID := "user-1", "event-1"
+		at := time.Unix(1_700_000_000, 0)
+		event, err := mp.NewEventFromStruct("signup", testPointerRoles{UserID: &userID, EventID: &eventID, At: &at, Plan: "pro"})
+		require.NoError(t, err)
+		require.Equal(t, "user-1", event.Properties[propertyDistinctID])
+		require.Equal(t, "event-1", event.Properties[propertyInsertID])
+		require.Equal(t, at.UnixMilli(), event.Properties[propertyTime])
+
+		event, err = mp.NewEventFromStruct("signup", testPointerRoles{Plan: "pro"})
+		require.NoError(t, err)
+		require.Equal(t, EmptyDistinctID, event.Properties[propertyDistinctID])
+		require.NotContains(t, event.Properties, propertyInsertID)
+		require.NotContains(t, event.Properties, propertyTime)
+	})
+
+	t.Run("invalid structs", func(t *testing.T) {
+		mp := NewApiClient("token")
+
+		_, err := mp.NewEventFromStruct("event", "not a struct")
+		require.Error(t, err)
+
+		_, err = mp.NewEventFromStruct("event", (*testSignup)(nil))
+		require.Error(t, err)
+
+		_, err = mp.NewEventFromStruct("event", struct {
+			ID int `mixpanel:"id,distinct_id"`
+		}{})
+		require.Error(t, err)
+
+		_, err = mp.NewEventFromStruct("event", struct {
+			A string `mixpanel:"a,distinct_id"`
+			B string `mixpanel:"b,distinct_id"`
+		}{})
+		require.Error(t, err)
+
+		_, err = mp.NewEventFromStruct("event", struct {
+			A string `mixpanel:"a,unknown"`
+		}{})
+		require.Error(t, err)
+	})
+
+	t.Run("plans are cached per type", func(t *testing.T) {
+		mp := NewApiClient("token")
+		_, err := mp.NewEventFromStruct("signup", testSignup{})
+		require.NoError(t, err)
+
+		first, err := planFor(reflect.TypeOf(testSignup{}))
+		require.NoError(t, err)
+		second, err := planFor(reflect.TypeOf(&testSignup{}).Elem())
+		require.NoError(t, err)
+		require.Same(t, first, second)
+	})
+}
+
+func TestDecodeProperties(t *testing.T) {
+	t.Run("round trips through json", func(t *testing.T) {
+		mp := NewApiClient("token")
+		trial := true
+		original := testSignup{
+			testCommon: testCommon{UserID: "user-1"},
+			EventID:    "event-1",
+			At:         time.Unix(1_700_000_000, 0),
+			Plan:       "pro",
+			Seats:      3,
+			Trial:      &trial,
+			Tags:       []string{"a", "b"},
+			RenewsAt:   time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+			Address:    testAddress{City: "Lisbon", Country: "PT"},
+			Referrer:   "google",
+		}
+		event, err := mp.NewEventFromStruct("signup", original)
+		require.NoError(t, err)
+
+		// decode like the export api results
+		data, err := json.Marshal(event)
+		require.NoError(t, err)
+		exported := &Event{}
+		require.NoError(t, json.Unmarshal(data, exported))
+
+		var decoded testSignup
+		require.NoError(t, exported.DecodeProperties(&decoded))
+		require.True(t, original.At.Equal(decoded.At))
+		decoded.At = original.At
+		require.Equal(t, original, decoded)
+	})
+
+	t.Run("time in seconds", func(t *testing.T) {
+		event := &Event{Properties: map[string]any{propertyTime: float64(1_700_000_000)}}
+		var decoded testSignup
+		require.NoError(t, event.DecodeProperties(&decoded))
+		require.Equal(t, int64(1_700_000_000), decoded.At.Unix())
+	})
+
+	t.Run("pointer role fields", func(t *testing.T) {
+		event := &Event{Properties: map[string]any{
+			propertyDistinctID: "user-1",
+			propertyInsertID:   "event-1",
+			propertyTime:       float64(1_700_000_000_000),
+			"plan":             "pro",
+		}}
+		var decoded testPointerRoles
+		require.NoError(t, event.DecodeProperties(&decoded))
+		require.NotNil(t, decoded.UserID)
+		require.Equal(t, "user-1", *decoded.UserID)
+		require.NotNil(t, decoded.EventID)
+		require.Equal(t, "event-1", *decoded.EventID)
+		require.NotNil(t, decoded.At)
+		require.Equal(t, int64(1_700_000_000), decoded.At.Unix())
+		require.Equal(t, "pro", decoded.Plan)
+	})
+
+	t.Run("invalid targets", func(t *testing.T) {
+		event := &Event{Properties: map[string]any{"seats": "three"}}
+		require.Error(t, event.DecodeProperties(testSignup{}))
+		require.Error(t, event.DecodeProperties((*testSignup)(nil)))
+		require.Error(t, event.DecodeProperties(&testSignup{}))
+	})
+}
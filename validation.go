@@ -0,0 +1,252 @@
+package mixpanel
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// https://developer.mixpanel.com/reference/import-events#validation
+
+	maxEventNameLength    = 255
+	maxEventProperties    = 255
+	maxPropertyKeyLength  = 255
+	maxPropertyDepth      = 3
+	maxInsertIDLength     = 36
+	maxEventTimeInFuture  = time.Hour
+	clientValidationError = "some data points in the request failed client side validation"
+)
+
+// ValidationMode is what the client does with events that fail validation
+type ValidationMode int
+
+const (
+	// ValidationReject fails the whole call without sending any event
+	ValidationReject ValidationMode = iota
+	// ValidationDrop sends the valid events and drops the invalid ones
+	ValidationDrop
+)
+
+type ValidationConfig struct {
+	Mode ValidationMode
+	// OnDrop is called with every event dropped by ValidationDrop
+	OnDrop func(event *Event, failed []ImportFailedRecords)
+}
+
+// WithEventValidation validates the events passed to Track and Import before making the http call
+func WithEventValidation(config ValidationConfig) Options {
+	return func(mixpanel *ApiClient) {
+		mixpanel.validation = &config
+	}
+}
+
+// ValidateEvent checks the event against the limits mixpanel enforces on ingestion
+// Returns the failures in the same shape as the import api
+func ValidateEvent(event *Event) []ImportFailedRecords {
+	return validateEvent(0, event, true)
+}
+
+// ValidateEvents checks the events against the limits mixpanel enforces on ingestion
+// The index of the failures is the index of the event in events
+func ValidateEvents(events []*Event) []ImportFailedRecords {
+	var failed []ImportFailedRecords
+	for i, event := range events {
+		failed = append(failed, validateEvent(i, event, true)...)
+	}
+	return failed
+}
+
+// validateEvent validates a single event, requireTime is false for the track api which defaults the time
+func validateEvent(index int, event *Event, requireTime bool) []ImportFailedRecords {
+	var failed []ImportFailedRecords
+	fail := func(field, message string) {
+		record := ImportFailedRecords{
+			Index:   index,
+			Field:   field,
+			Message: message,
+		}
+		if event != nil {
+			if insertID, ok := event.Properties[propertyInsertID].(string); ok {
+				record.InsertID = insertID
+			}
+		}
+		failed = append(failed, record)
+	}
+
+	if event == nil {
+		fail("event", "event must not be nil")
+		return failed
+	}
+
+	if event.Name == "" {
+		fail("event", "'event' must not be missing or blank")
+	} else if utf8.RuneCountInString(event.Name) > maxEventNameLength {
+		fail("event", fmt.Sprintf("'event' must not be longer than %d characters", maxEventNameLength))
+	}
+
+	if event.Properties == nil {
+		fail("properties", "'properties' must not be missing")
+		return failed
+	}
+	if len(event.Properties) > maxEventProperties {
+		fail("properties", fmt.Sprintf("'properties' must not have more than %d properties", maxEventProperties))
+	}
+
+	if _, ok := event.Properties[propertyDistinctID]; !ok {
+		fail("properties.distinct_id", "'properties.distinct_id' must not be missing")
+	} else if _, ok := event.Properties[propertyDistinctID].(string); !ok {
+		fail("properties.distinct_id", "'properties.distinct_id' must be a string")
+	}
+
+	if t, ok := event.Properties[propertyTime]; ok {
+		if message := validateTime(t); message != "" {
+			fail("properties.time", message)
+		}
+	} else if requireTime {
+		fail("properties.time", "'properties.time' must not be missing")
+	}
+
+	if insertID, ok := event.Properties[propertyInsertID]; ok {
+		if message := validateInsertID(insertID); message != "" {
+			fail("properties.$insert_id", message)
+		}
+	}
+
+	for key, value := range event.Properties {
+		if utf8.RuneCountInString(key) > maxPropertyKeyLength {
+			fail("properties."+key, fmt.Sprintf("property names must not be longer than %d characters", maxPropertyKeyLength))
+		}
+		if propertyDepth(value) > maxPropertyDepth {
+			fail("properties."+key, fmt.Sprintf("properties must not be nested more than %d levels deep", maxPropertyDepth))
+		}
+	}
+
+	return failed
+}
+
+func validateTime(value any) string {
+	var t time.Time
+	switch v := value.(type) {
+	case int64:
+		t = timeFromEpoch(float64(v))
+	case int:
+		t = timeFromEpoch(float64(v))
+	case float64:
+		t = timeFromEpoch(v)
+	default:
+		return "'properties.time' must be a unix timestamp"
+	}
+
+	if t.After(time.Now().Add(maxEventTimeInFuture)) {
+		return "'properties.time' must not be in the future"
+	}
+	return ""
+}
+
+// timeFromEpoch accepts seconds or milliseconds like the import api
+func timeFromEpoch(epoch float64) time.Time {
+	// any timestamp in milliseconds is larger than a timestamp in seconds until the year 5138
+	if epoch > 1e11 {
+		return time.UnixMilli(int64(epoch))
+	}
+	return time.Unix(int64(epoch), 0)
+}
+
+func validateInsertID(value any) string {
+	insertID, ok := value.(string)
+	if !ok {
+		return "'properties.$insert_id' must be a string"
+	}
+	if insertID == "" || len(insertID) > maxInsertIDLength {
+		return fmt.Sprintf("'properties.$insert_id' must be between 1 and %d characters", maxInsertIDLength)
+	}
+	for _, r := range insertID {
+		if !isInsertIDRune(r) {
+			return "'properties.$insert_id' must only contain alphanumeric characters and dashes"
+		}
+	}
+	return ""
+}
+
+func isInsertIDRune(r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-'
+}
+
+// propertyDepth returns how many levels of objects the value has
+func propertyDepth(value any) int {
+	switch v := value.(type) {
+	case map[string]any:
+		depth := 0
+		for _, nested := range v {
+			if d := propertyDepth(nested); d > depth {
+				depth = d
+			}
+		}
+		return depth + 1
+	case []any:
+		depth := 0
+		for _, nested := range v {
+			if d := propertyDepth(nested); d > depth {
+				depth = d
+			}
+		}
+		return depth
+	default:
+		return 0
+	}
+}
+
+// validateBeforeSend applies the client validation config to the events
+// Returns the events to send and the index in events of every event returned
+func (m *ApiClient) validateBeforeSend(events []*Event, requireTime bool) ([]*Event, []int, error) {
+	if m.validation == nil {
+		return events, nil, nil
+	}
+
+	var failed []ImportFailedRecords
+	valid := make([]*Event, 0, len(events))
+	indexes := make([]int, 0, len(events))
+	for i, event := range events {
+		eventFailed := validateEvent(i, event, requireTime)
+		if len(eventFailed) == 0 {
+			valid = append(valid, event)
+			indexes = append(indexes, i)
+			continue
+		}
+
+		failed = append(failed, eventFailed...)
+		if m.validation.Mode == ValidationDrop && m.validation.OnDrop != nil {
+			m.validation.OnDrop(event, eventFailed)
+		}
+	}
+
+	if len(failed) > 0 && m.validation.Mode == ValidationReject {
+		return nil, nil, ImportFailedValidationError{
+			Code:                http.StatusBadRequest,
+			ApiError:            clientValidationError,
+			FailedImportRecords: failed,
+		}
+	}
+	return valid, indexes, nil
+}
+
+// remapFailedRecords maps the index of the failed records of the sent events back to the index in the events passed by the caller
+func remapFailedRecords(err error, indexes []int) error {
+	var validationError ImportFailedValidationError
+	if indexes == nil || !errors.As(err, &validationError) {
+		return err
+	}
+
+	failedRecords := make([]ImportFailedRecords, len(validationError.FailedImportRecords))
+	for i, record := range validationError.FailedImportRecords {
+		if record.Index >= 0 && record.Index < len(indexes) {
+			record.Index = indexes[record.Index]
+		}
+		failedRecords[i] = record
+	}
+	validationError.FailedImportRecords = failedRecords
+	return validationError
+}
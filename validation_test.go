@@ -0,0 +1,160 @@
+package mixpanel
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jarcoal/httpmock"
+	"github.com/stretchr/testify/require"
+)
+
+func validEvent(mp *ApiClient) *Event {
+	event := mp.NewEvent("valid event", "some-id", nil)
+	event.AddTime(time.Now())
+	event.AddInsertID("b3c1d2e4-0000-4000-8000-123456789abc")
+	return event
+}
+
+func TestValidateEvent(t *testing.T) {
+	mp := NewApiClient("token")
+
+	t.Run("valid event", func(t *testing.T) {
+		require.Empty(t, ValidateEvent(validEvent(mp)))
+	})
+
+	tests := []struct {
+		name   string
+		modify func(event *Event)
+		field  string
+	}{
+		{"missing name", func(event *Event) { event.Name = "" }, "event"},
+		{"name too long", func(event *Event) { event.Name = strings.Repeat("a", 256) }, "event"},
+		{"missing time", func(event *Event) { delete(event.Properties, propertyTime) }, "properties.time"},
+		{"time is not a number", func(event *Event) { event.Properties[propertyTime] = "yesterday" }, "properties.time"},
+		{"time in the future", func(event *Event) { event.AddTime(time.Now().Add(24 * time.Hour)) }, "properties.time"},
+		{"missing distinct id", func(event *Event) { delete(event.Properties, propertyDistinctID) }, "properties.distinct_id"},
+		{"insert id too long", func(event *Event) { event.AddInsertID(strings.Repeat("a", 37)) }, "properties.$insert_id"},
+		{"insert id with invalid characters", func(event *Event) { event.AddInsertID("not_valid!") }, "properties.$insert_id"},
+		{"key too long", func(event *Event) { event.Properties[strings.Repeat("k", 256)] = 1 }, "properties." + strings.Repeat("k", 256)},
+		{"nested too deep", func(event *Event) {
+			event.Properties["nested"] = map[string]any{"a": map[string]any{"b": map[string]any{"c": map[string]any{"d": 1}}}}
+		}, "properties.nested"},
+		{"too many properties", func(event *Event) {
+			for i := 0; i < maxEventProperties; i++ {
+				event.Properties[fmt.Sprintf("property_%d", i)] = i
+			}
+		}, "properties"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := validEvent(mp)
+			test.modify(event)
+
+			failed := ValidateEvent(event)
+			require.Len(t, failed, 1)
+			require.Equal(t, test.field, failed[0].Field)
+			require.NotEmpty(t, failed[0].Message)
+		})
+	}
+
+	t.Run("time in seconds is accepted", func(t *testing.T) {
+		event := validEvent(mp)
+		event.Properties[propertyTime] = float64(time.Now().Unix())
+		require.Empty(t, ValidateEvent(event))
+	})
+
+	t.Run("nested objects up to the limit are accepted", func(t *testing.T) {
+		event := validEvent(mp)
+		event.Properties["nested"] = map[string]any{"a": map[string]any{"b": map[string]any{"c": 1}}}
+		require.Empty(t, ValidateEvent(event))
+	})
+}
+
+func TestValidateEvents(t *testing.T) {
+	mp := NewApiClient("token")
+	invalid := validEvent(mp)
+	invalid.Name = ""
+
+	failed := ValidateEvents([]*Event{validEvent(mp), invalid})
+	require.Len(t, failed, 1)
+	require.Equal(t, 1, failed[0].Index)
+	require.Equal(t, invalid.Properties[propertyInsertID], failed[0].InsertID)
+}
+
+func TestClientValidation(t *testing.T) {
+	t.Run("rejects invalid events before sending", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithEventValidation(ValidationConfig{Mode: ValidationReject}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		invalid := validEvent(mp)
+		delete(invalid.Properties, propertyTime)
+		_, err := mp.Import(ctx, []*Event{validEvent(mp), invalid}, ImportOptionsRecommend)
+
+		validationError := ImportFailedValidationError{}
+		require.ErrorAs(t, err, &validationError)
+		require.Equal(t, 1, validationError.FailedImportRecords[0].Index)
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+
+	t.Run("track does not require time", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithEventValidation(ValidationConfig{Mode: ValidationReject}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, mp.apiEndpoint+trackURL, httpmock.NewStringResponder(http.StatusOK, `{"error": "", "status": 1}`))
+
+		require.NoError(t, mp.Track(ctx, []*Event{mp.NewEvent("event", EmptyDistinctID, nil)}))
+	})
+
+	t.Run("drops invalid events and maps server failures back", func(t *testing.T) {
+		ctx := context.Background()
+		var dropped []*Event
+		mp := NewApiClient("token", WithEventValidation(ValidationConfig{
+			Mode: ValidationDrop,
+			OnDrop: func(event *Event, failed []ImportFailedRecords) {
+				require.NotEmpty(t, failed)
+				dropped = append(dropped, event)
+			},
+		}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+		httpmock.RegisterResponder(http.MethodPost, mp.apiEndpoint+importURL, func(req *http.Request) (*http.Response, error) {
+			require.Len(t, decodeImportRequest(t, req), 2)
+			return httpmock.NewStringResponse(http.StatusBadRequest, `{"code": 400, "error": "invalid", "num_records_imported": 1, "failed_records": [{"index": 1, "field": "event", "message": "invalid"}]}`), nil
+		})
+
+		invalid := validEvent(mp)
+		invalid.Name = ""
+		_, err := mp.Import(ctx, []*Event{validEvent(mp), invalid, validEvent(mp)}, ImportOptionsRecommend)
+
+		validationError := ImportFailedValidationError{}
+		require.ErrorAs(t, err, &validationError)
+		require.Equal(t, 2, validationError.FailedImportRecords[0].Index)
+		require.Equal(t, []*Event{invalid}, dropped)
+	})
+
+	t.Run("nothing is sent when every event is dropped", func(t *testing.T) {
+		ctx := context.Background()
+		mp := NewApiClient("token", WithEventValidation(ValidationConfig{Mode: ValidationDrop}))
+
+		httpmock.Activate()
+		t.Cleanup(httpmock.DeactivateAndReset)
+
+		invalid := validEvent(mp)
+		invalid.Name = ""
+		success, err := mp.Import(ctx, []*Event{invalid}, ImportOptionsRecommend)
+		require.NoError(t, err)
+		require.Equal(t, 0, success.NumRecordsImported)
+		require.Equal(t, 0, httpmock.GetTotalCallCount())
+	})
+}